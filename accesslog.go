@@ -0,0 +1,90 @@
+package grpcasync
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// AccessLogEntry describes one call handled by the server.
+type AccessLogEntry struct {
+	Time      time.Time
+	Method    string
+	Code      codes.Code
+	Duration  time.Duration
+	Peer      string
+	RequestID string
+	User      string
+}
+
+// AccessLogInterceptor returns a server interceptor passing an
+// AccessLogEntry to sink for every unary call once it is handled, leaving the
+// choice of logging library and format to the sink. The request ID is read
+// from RequestIDMetadataKey and the user from userKey. The duration is taken
+// from the CallTimer of the call when CallTimerInterceptor is installed.
+func AccessLogInterceptor(userKey string, sink func(AccessLogEntry)) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		timer := CallTimerFromContext(ctx)
+		res, err := handler(ctx, req)
+		sink(accessLogEntry(ctx, info.FullMethod, userKey, timer, err))
+
+		return res, err
+	}
+}
+
+// AccessLogStreamInterceptor is the streaming variant of
+// AccessLogInterceptor, it logs each stream once it has ended.
+func AccessLogStreamInterceptor(userKey string, sink func(AccessLogEntry)) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx := stream.Context()
+		timer := CallTimerFromContext(ctx)
+		err := handler(srv, stream)
+		sink(accessLogEntry(ctx, info.FullMethod, userKey, timer, err))
+
+		return err
+	}
+}
+
+func accessLogEntry(
+	ctx context.Context,
+	method string,
+	userKey string,
+	timer *CallTimer,
+	err error,
+) AccessLogEntry {
+	entry := AccessLogEntry{
+		Time:     timer.Start(),
+		Method:   method,
+		Code:     status.Code(err),
+		Duration: timer.Stop(),
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		entry.Peer = p.Addr.String()
+	}
+
+	if values := metadata.ValueFromIncomingContext(ctx, RequestIDMetadataKey); len(values) > 0 {
+		entry.RequestID = values[0]
+	}
+
+	if values := metadata.ValueFromIncomingContext(ctx, userKey); len(values) > 0 {
+		entry.User = values[0]
+	}
+
+	return entry
+}
@@ -0,0 +1,59 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccessLogInterceptor(t *testing.T) {
+	entries := make(chan AccessLogEntry, 1)
+	sink := func(entry AccessLogEntry) { entries <- entry }
+
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		if req.Name == "" {
+			return nil, status.Error(codes.NotFound, "no such user")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		return &examples.Response{Message: "Hello, " + req.Name}, nil
+	}}, []grpc.ServerOption{grpc.UnaryInterceptor(AccessLogInterceptor("x-user", sink))})
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		RequestIDMetadataKey, "req-1",
+		"x-user", "alice",
+	)
+	start := time.Now()
+
+	if _, err := client.SayHello(ctx, &examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := <-entries
+
+	if entry.Method != sayHelloMethod || entry.Code != codes.OK || entry.RequestID != "req-1" || entry.User != "alice" {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	if entry.Duration < 10*time.Millisecond {
+		t.Errorf("duration = %v, shorter than the handler", entry.Duration)
+	} else if entry.Time.Before(start) || entry.Time.After(time.Now()) {
+		t.Errorf("time = %v, outside of the call", entry.Time)
+	} else if entry.Peer == "" {
+		t.Error("got no peer")
+	}
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{}); status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+
+	if entry := <-entries; entry.Code != codes.NotFound || entry.RequestID != "" || entry.User != "" {
+		t.Errorf("entry of a failed anonymous call = %+v", entry)
+	}
+}
@@ -0,0 +1,97 @@
+package grpcasync
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// ActiveCall describes a call that is being handled.
+type ActiveCall struct {
+	Method string
+	Start  time.Time
+	Peer   string
+}
+
+// CallRegistry keeps track of the calls a server is currently handling, e.g.
+// to back a /debug/grpc page. Install its interceptors on the server and read
+// the calls with ActiveCalls. The zero value is ready to use.
+type CallRegistry struct {
+	mu    sync.Mutex
+	next  uint64
+	calls map[uint64]ActiveCall
+}
+
+// ActiveCalls returns the calls in progress, oldest first.
+func (r *CallRegistry) ActiveCalls() []ActiveCall {
+	r.mu.Lock()
+	calls := make([]ActiveCall, 0, len(r.calls))
+
+	for _, call := range r.calls {
+		calls = append(calls, call)
+	}
+
+	r.mu.Unlock()
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Start.Before(calls[j].Start)
+	})
+
+	return calls
+}
+
+// UnaryInterceptor returns a server interceptor registering unary calls.
+func (r *CallRegistry) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		defer r.register(ctx, info.FullMethod)()
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns a server interceptor registering streams.
+func (r *CallRegistry) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		defer r.register(stream.Context(), info.FullMethod)()
+		return handler(srv, stream)
+	}
+}
+
+// register records a call and returns the function removing it, which is
+// deferred so that the call is removed even if the handler panics.
+func (r *CallRegistry) register(ctx context.Context, method string) func() {
+	call := ActiveCall{Method: method, Start: time.Now()}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		call.Peer = p.Addr.String()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.calls == nil {
+		r.calls = map[uint64]ActiveCall{}
+	}
+
+	id := r.next
+	r.next++
+	r.calls[id] = call
+
+	return func() {
+		r.mu.Lock()
+		delete(r.calls, id)
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,92 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// waitNoActiveCalls waits for the registry to empty, as a call is only
+// unregistered after its handler returned.
+func waitNoActiveCalls(t *testing.T, r *CallRegistry) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+
+	for len(r.ActiveCalls()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if calls := r.ActiveCalls(); len(calls) > 0 {
+		t.Errorf("calls still registered: %v", calls)
+	}
+}
+
+func TestCallRegistry(t *testing.T) {
+	registry := &CallRegistry{}
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(registry.UnaryInterceptor()),
+		grpc.StreamInterceptor(registry.StreamInterceptor()),
+	})
+
+	stream, err := client.SayHelloDuplex(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err := stream.Send(&examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	} else if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := registry.ActiveCalls()
+
+	if len(calls) != 1 || calls[0].Method != duplexMethod {
+		t.Fatalf("active calls = %v, want the duplex stream", calls)
+	} else if calls[0].Peer == "" || calls[0].Start.IsZero() {
+		t.Errorf("incomplete call %+v", calls[0])
+	}
+
+	stream.CloseSend()
+
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("err = %v, want io.EOF", err)
+	}
+
+	waitNoActiveCalls(t, registry)
+}
+
+func TestCallRegistryPanic(t *testing.T) {
+	registry := &CallRegistry{}
+	recovery := func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (res any, err error) {
+		defer func() {
+			if recover() != nil {
+				err = status.Error(codes.Internal, "panic")
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		panic("boom")
+	}}, []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(recovery, registry.UnaryInterceptor()),
+	})
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{}); status.Code(err) != codes.Internal {
+		t.Fatalf("err = %v, want Internal", err)
+	}
+
+	waitNoActiveCalls(t, registry)
+}
@@ -0,0 +1,83 @@
+package grpcasync
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+)
+
+// AffinityBalancerName is the name of the balancer routing calls that share
+// an affinity key to the same backend, selected in the service config:
+//
+//	config := grpcasync.ServiceConfig{LoadBalancingPolicy: grpcasync.AffinityBalancerName}
+//
+// Calls without a key are spread round-robin. Keys are mapped to backends by
+// rendezvous hashing, so a key sticks to its backend as long as that backend
+// is ready, and if it goes away only its keys move to the remaining ones.
+const AffinityBalancerName = "grpcasync_affinity"
+
+func init() {
+	balancer.Register(base.NewBalancerBuilder(
+		AffinityBalancerName,
+		affinityPickerBuilder{},
+		base.Config{HealthCheck: true},
+	))
+}
+
+type affinityKey struct{}
+
+// WithAffinityKey returns a context whose calls are routed by the affinity
+// balancer to the backend of key, e.g. a session ID.
+func WithAffinityKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, affinityKey{}, key)
+}
+
+type affinityPickerBuilder struct{}
+
+func (affinityPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+
+	p := &affinityPicker{}
+
+	for sc, sci := range info.ReadySCs {
+		p.conns = append(p.conns, affinityConn{sc, sci.Address.Addr})
+	}
+
+	// Sorting keeps the round-robin order stable across rebuilds.
+	sort.Slice(p.conns, func(i, j int) bool { return p.conns[i].addr < p.conns[j].addr })
+	return p
+}
+
+type affinityConn struct {
+	sc   balancer.SubConn
+	addr string
+}
+
+type affinityPicker struct {
+	conns []affinityConn
+	next  atomic.Uint32
+}
+
+func (p *affinityPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	key, ok := info.Ctx.Value(affinityKey{}).(string)
+
+	if !ok {
+		i := p.next.Add(1) % uint32(len(p.conns))
+		return balancer.PickResult{SubConn: p.conns[i].sc}, nil
+	}
+
+	best, bestScore := 0, uint32(0)
+
+	for i, conn := range p.conns {
+		if score := hashKey(key + "|" + conn.addr); i == 0 || score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	return balancer.PickResult{SubConn: p.conns[best].sc}, nil
+}
@@ -0,0 +1,78 @@
+package grpcasync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+func TestAffinityBalancer(t *testing.T) {
+	r := manual.NewBuilderWithScheme("affinity")
+	r.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: "a"}, {Addr: "b"}}})
+	config := ServiceConfig{LoadBalancingPolicy: AffinityBalancerName}
+
+	conn, err := grpc.Dial("affinity:///greeter",
+		serveBackends(t, "a", "b"),
+		grpc.WithResolvers(r),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(config.JSON()),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	client := examples.NewGreeterClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	call := func(ctx context.Context) string {
+		res, err := client.SayHello(ctx, &examples.Request{}, grpc.WaitForReady(true))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res.Message
+	}
+
+	// Calls without a key are spread, which also waits for both backends.
+	for seen := map[string]bool{}; len(seen) < 2; {
+		seen[call(ctx)] = true
+	}
+
+	backends := map[string]string{}
+
+	for i := 0; i < 8; i++ {
+		key := fmt.Sprintf("session-%d", i%4)
+		backend := call(WithAffinityKey(ctx, key))
+
+		if prev, ok := backends[key]; ok && backend != prev {
+			t.Errorf("%s went to %s after %s", key, backend, prev)
+		}
+
+		backends[key] = backend
+	}
+
+	// Once the backend of a key goes away, the key falls back to the other.
+	key := "session-0"
+	gone, other := backends[key], "a"
+
+	if gone == "a" {
+		other = "b"
+	}
+
+	r.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: other}}})
+
+	for call(WithAffinityKey(ctx, key)) != other {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
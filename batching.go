@@ -0,0 +1,118 @@
+package grpcasync
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// BatchingSender accumulates messages destined for a server stream and sends
+// them as a single merged message once size messages are pending or interval
+// has elapsed, which cuts frame overhead for chatty streams of tiny messages.
+//
+// Call Close before the handler returns to send what is left and stop the
+// interval timer.
+type BatchingSender[Res any] struct {
+	stream  grpc.ServerStream
+	size    int
+	merge   func(batch []*Res) *Res
+	mu      sync.Mutex
+	pending []*Res
+	err     error
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewBatchingSender creates a BatchingSender for stream. merge combines the
+// pending messages into the one that is actually sent. A non-positive
+// interval disables time-based flushing.
+func NewBatchingSender[Res any](
+	stream grpc.ServerStream,
+	size int,
+	interval time.Duration,
+	merge func(batch []*Res) *Res,
+) *BatchingSender[Res] {
+	b := &BatchingSender[Res]{
+		stream: stream,
+		size:   size,
+		merge:  merge,
+		done:   make(chan struct{}),
+	}
+
+	if interval > 0 {
+		b.wg.Add(1)
+		go b.tick(interval)
+	}
+
+	return b
+}
+
+// Add queues msg, flushing the batch if it reaches the size threshold. It
+// returns the error of any earlier failed flush, after which the sender is
+// unusable.
+func (b *BatchingSender[Res]) Add(msg *Res) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+
+	b.pending = append(b.pending, msg)
+
+	if len(b.pending) >= b.size {
+		return b.flush()
+	}
+
+	return nil
+}
+
+// Flush sends the pending messages immediately.
+func (b *BatchingSender[Res]) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.flush()
+}
+
+// Close stops time-based flushing and sends the remaining messages. Calling
+// it again does nothing and returns the result of the first call.
+func (b *BatchingSender[Res]) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.done)
+		b.wg.Wait()
+		b.closeErr = b.Flush()
+	})
+
+	return b.closeErr
+}
+
+func (b *BatchingSender[Res]) flush() error {
+	if b.err != nil || len(b.pending) == 0 {
+		return b.err
+	}
+
+	b.err = b.stream.SendMsg(b.merge(b.pending))
+	b.pending = nil
+
+	return b.err
+}
+
+func (b *BatchingSender[Res]) tick(interval time.Duration) {
+	defer b.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			b.Flush()
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+)
+
+func joinResponses(batch []*examples.Response) *examples.Response {
+	msgs := make([]string, len(batch))
+
+	for i, res := range batch {
+		msgs[i] = res.Message
+	}
+
+	return &examples.Response{Message: strings.Join(msgs, ",")}
+}
+
+// batchingGreeter streams count numbered replies, merged by a BatchingSender
+// of the given size unless size is 0.
+type batchingGreeter struct {
+	greeter
+	count    int
+	size     int
+	interval time.Duration
+	pause    time.Duration
+}
+
+func (g *batchingGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	if g.size == 0 {
+		for i := 1; i <= g.count; i++ {
+			if err := stream.Send(&examples.Response{Message: strconv.Itoa(i)}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// Closing again on the way out, as handlers do to cover their error
+	// paths, must not fail.
+	sender := NewBatchingSender(stream, g.size, g.interval, joinResponses)
+	defer sender.Close()
+
+	for i := 1; i <= g.count; i++ {
+		if err := sender.Add(&examples.Response{Message: strconv.Itoa(i)}); err != nil {
+			return err
+		}
+	}
+
+	time.Sleep(g.pause)
+	return sender.Close()
+}
+
+func recvAll(t testing.TB, client examples.GreeterClient) []string {
+	t.Helper()
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+
+	for {
+		res, err := stream.Recv()
+
+		if err == io.EOF {
+			return msgs
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs = append(msgs, res.Message)
+	}
+}
+
+func TestBatchingSenderSize(t *testing.T) {
+	client, _ := startGreeter(t, &batchingGreeter{count: 7, size: 3}, nil)
+	got := strings.Join(recvAll(t, client), " ")
+
+	if want := "1,2,3 4,5,6 7"; got != want {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestBatchingSenderInterval(t *testing.T) {
+	impl := &batchingGreeter{count: 2, size: 100, interval: 20 * time.Millisecond, pause: 200 * time.Millisecond}
+	client, _ := startGreeter(t, impl, nil)
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	res, err := stream.Recv()
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Message != "1,2" {
+		t.Errorf("message = %q, want 1,2", res.Message)
+	} else if elapsed := time.Since(start); elapsed >= impl.pause {
+		t.Errorf("batch arrived after %v, not flushed by the interval", elapsed)
+	}
+}
+
+func BenchmarkBatchingSender(b *testing.B) {
+	for _, size := range []int{0, 10, 100} {
+		name := "batch=" + strconv.Itoa(size)
+
+		if size == 0 {
+			name = "per-message"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			client, _ := startGreeter(b, &batchingGreeter{count: 1000, size: size}, nil)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				recvAll(b, client)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package grpcasync
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// CallDetails describes what was negotiated for a call, for debugging
+// interoperability issues.
+type CallDetails struct {
+	// Compressor is the name of the compressor of the request messages, or
+	// empty if they are not compressed.
+	Compressor string
+	// AcceptedCompressors are the compressors the client accepts for the
+	// response messages.
+	AcceptedCompressors []string
+	// TLS is true if the connection is secured by TLS.
+	TLS bool
+	// Authority is the authority the client addressed, usually host:port.
+	Authority string
+}
+
+type callDetailsKey struct{}
+
+// CallInfo returns the CallDetails of the call handled with ctx, which are
+// only available if CallInfoInterceptor or CallInfoStreamInterceptor is
+// installed.
+func CallInfo(ctx context.Context) (CallDetails, bool) {
+	details, ok := ctx.Value(callDetailsKey{}).(CallDetails)
+	return details, ok
+}
+
+// CallInfoInterceptor returns a server interceptor making the CallDetails of
+// unary calls available to handlers through CallInfo.
+func CallInfoInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(withCallDetails(ctx), req)
+	}
+}
+
+// CallInfoStreamInterceptor is the streaming variant of CallInfoInterceptor.
+func CallInfoStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &contextServerStream{stream, withCallDetails(stream.Context())})
+	}
+}
+
+func withCallDetails(ctx context.Context) context.Context {
+	var details CallDetails
+
+	// The compressor is not part of the metadata, but the transport stream
+	// of gRPC-Go exposes it.
+	if s, ok := grpc.ServerTransportStreamFromContext(ctx).(interface{ RecvCompress() string }); ok {
+		details.Compressor = s.RecvCompress()
+	}
+
+	details.AcceptedCompressors, _ = grpc.ClientSupportedCompressors(ctx)
+
+	if p, ok := peer.FromContext(ctx); ok {
+		_, details.TLS = p.AuthInfo.(credentials.TLSInfo)
+	}
+
+	if values := metadata.ValueFromIncomingContext(ctx, ":authority"); len(values) > 0 {
+		details.Authority = values[0]
+	}
+
+	return context.WithValue(ctx, callDetailsKey{}, details)
+}
@@ -0,0 +1,55 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+func TestCallInfoInterceptor(t *testing.T) {
+	details := make(chan CallDetails, 1)
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		d, ok := CallInfo(ctx)
+
+		if !ok {
+			t.Error("no call details in the handler")
+		}
+
+		details <- d
+		return &examples.Response{}, nil
+	}}, []grpc.ServerOption{grpc.UnaryInterceptor(CallInfoInterceptor())})
+
+	tests := []struct {
+		name       string
+		opts       []grpc.CallOption
+		compressor string
+	}{
+		{"gzip", []grpc.CallOption{grpc.UseCompressor(gzip.Name)}, "gzip"},
+		{"uncompressed", nil, ""},
+	}
+
+	for _, tt := range tests {
+		if _, err := client.SayHello(context.Background(), &examples.Request{Name: "World"}, tt.opts...); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		d := <-details
+
+		if d.Compressor != tt.compressor {
+			t.Errorf("%s: compressor = %q, want %q", tt.name, d.Compressor, tt.compressor)
+		} else if d.TLS {
+			t.Errorf("%s: reported TLS over an insecure connection", tt.name)
+		} else if d.Authority != "bufconn" {
+			t.Errorf("%s: authority = %q, want %q", tt.name, d.Authority, "bufconn")
+		}
+	}
+}
+
+func TestCallInfoMissing(t *testing.T) {
+	if _, ok := CallInfo(context.Background()); ok {
+		t.Error("got call details without the interceptor")
+	}
+}
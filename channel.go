@@ -0,0 +1,76 @@
+package grpcasync
+
+import "context"
+
+// TeeStream forwards every value received from in, unchanged, to the returned
+// channel after passing it to sink, e.g. for logging a stream as it flows.
+// The returned channel is closed once in is closed. Since forwarding is
+// unbuffered, a slow consumer or sink slows down the producer. With
+// WithWorkerPool, TeeStream waits for a free worker before it returns.
+func TeeStream[Res any](in <-chan Res, sink func(Res), opts ...StreamOption) <-chan Res {
+	out := make(chan Res)
+
+	workerPool(opts).Go(func() {
+		defer close(out)
+
+		for value := range in {
+			sink(value)
+			out <- value
+		}
+	})
+
+	return out
+}
+
+// Paginate calls a paginated unary method, starting with first and following
+// next to build the request of each further page until it reports false, and
+// yields the items of all pages on a single channel. The channel is closed
+// when the pages run out, a call fails or ctx is done; the error channel then
+// receives the failure, or nil.
+func Paginate[Req any, Res any, Item any](
+	ctx context.Context,
+	first *Req,
+	call func(ctx context.Context, req *Req) (*Res, error),
+	next func(res *Res) (*Req, bool),
+	items func(res *Res) []Item,
+	opts ...StreamOption,
+) (<-chan Item, <-chan error) {
+	out := make(chan Item)
+	errc := make(chan error, 1)
+
+	err := workerPool(opts).GoCtx(ctx, func() {
+		defer close(out)
+
+		for req := first; ; {
+			res, err := call(ctx, req)
+
+			if err != nil {
+				errc <- err
+				return
+			}
+
+			for _, item := range items(res) {
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+
+			var ok bool
+
+			if req, ok = next(res); !ok {
+				errc <- nil
+				return
+			}
+		}
+	})
+
+	if err != nil {
+		close(out)
+		errc <- err
+	}
+
+	return out, errc
+}
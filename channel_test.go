@@ -0,0 +1,145 @@
+package grpcasync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// replyChannel streams the replies of SayHelloStreamReply for name onto a
+// channel, which is closed when the stream ends.
+func replyChannel(t *testing.T, client examples.GreeterClient, name string) <-chan *examples.Response {
+	t.Helper()
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{Name: name})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan *examples.Response)
+
+	go func() {
+		defer close(ch)
+
+		for {
+			res, err := stream.Recv()
+
+			if err != nil {
+				return
+			}
+
+			ch <- res
+		}
+	}()
+
+	return ch
+}
+
+func TestTeeStream(t *testing.T) {
+	client, conn := startGreeter(t, &greeter{}, nil)
+
+	assertNoLeak(t, conn, func() {
+		observed := 0
+		out := TeeStream(replyChannel(t, client, "World"), func(*examples.Response) {
+			observed++
+		})
+
+		var msgs []string
+
+		// The loop ends only if closing in is propagated to out.
+		for res := range out {
+			msgs = append(msgs, res.Message)
+		}
+
+		if observed != 3 {
+			t.Errorf("sink observed %d messages, want 3", observed)
+		}
+
+		want := []string{"Hello 1: World", "Hello 2: World", "Hello 3: World"}
+
+		if len(msgs) != len(want) {
+			t.Fatalf("forwarded %q, want %q", msgs, want)
+		}
+
+		for i := range want {
+			if msgs[i] != want[i] {
+				t.Errorf("message %d = %q, want %q", i, msgs[i], want[i])
+			}
+		}
+	})
+}
+
+func TestPaginate(t *testing.T) {
+	// The Greeter pages through a fixed list of names, the request names the
+	// page and the response carries its names followed by the next page.
+	pages := map[string]string{
+		"":  "Alice,Bob|2",
+		"2": "Carol,Dave|3",
+		"3": "Eve|",
+	}
+	var calls int
+	client, conn := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		calls++
+		return &examples.Response{Message: pages[req.Name]}, nil
+	}}, nil)
+
+	assertNoLeak(t, conn, func() {
+		items, errc := Paginate(context.Background(), &examples.Request{},
+			func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+				return client.SayHello(ctx, req)
+			},
+			func(res *examples.Response) (*examples.Request, bool) {
+				_, token, _ := strings.Cut(res.Message, "|")
+				return &examples.Request{Name: token}, token != ""
+			},
+			func(res *examples.Response) []string {
+				names, _, _ := strings.Cut(res.Message, "|")
+				return strings.Split(names, ",")
+			},
+		)
+
+		var got []string
+
+		for item := range items {
+			got = append(got, item)
+		}
+
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+
+		if want := "Alice Bob Carol Dave Eve"; strings.Join(got, " ") != want {
+			t.Errorf("items = %q, want %q", got, want)
+		}
+
+		if calls != 3 {
+			t.Errorf("%d pages requested, want 3", calls)
+		}
+	})
+}
+
+func TestPaginateError(t *testing.T) {
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		return nil, status.Error(codes.Unavailable, "down")
+	}}, nil)
+
+	items, errc := Paginate(context.Background(), &examples.Request{},
+		func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+			return client.SayHello(ctx, req)
+		},
+		func(res *examples.Response) (*examples.Request, bool) { return nil, false },
+		func(res *examples.Response) []string { return nil },
+	)
+
+	for range items {
+		t.Error("item received from a failed page")
+	}
+
+	if err := <-errc; status.Code(err) != codes.Unavailable {
+		t.Errorf("err = %v, want Unavailable", err)
+	}
+}
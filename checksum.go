@@ -0,0 +1,168 @@
+package grpcasync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	protoenc "google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ChecksumMetadataKey is the trailer carrying the response checksum, its value
+// has the form "<algorithm>:<hex digest>".
+const ChecksumMetadataKey = "x-response-checksum"
+
+// ChecksumAlgorithm selects the hash used by ChecksumServerOptions.
+type ChecksumAlgorithm string
+
+// The supported checksum algorithms.
+const (
+	CRC32  ChecksumAlgorithm = "crc32"
+	SHA256 ChecksumAlgorithm = "sha256"
+)
+
+func (alg ChecksumAlgorithm) new() (hash.Hash, error) {
+	switch alg {
+	case CRC32:
+		return crc32.NewIEEE(), nil
+	case SHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("grpcasync: unknown checksum algorithm %q", alg)
+	}
+}
+
+func (alg ChecksumAlgorithm) sum(data []byte) (string, error) {
+	h, err := alg.new()
+
+	if err != nil {
+		return "", err
+	}
+
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ChecksumServerOptions returns server options that attach a checksum of the
+// unary response, as serialized on the wire, as a trailer for
+// ChecksumClientInterceptor to verify. Because the bytes are hashed rather
+// than a re-serialization of the decoded message, the checksum holds even if
+// client and server were compiled against different versions of the schema.
+//
+// The options install the interceptor, which should precede all others so
+// that they see the actual responses, and force a codec that passes the
+// responses it serialized through while delegating everything else to the
+// proto codec, so they can't be combined with other codecs.
+func ChecksumServerOptions(alg ChecksumAlgorithm) []grpc.ServerOption {
+	codec := checksumCodec{encoding.GetCodec(protoenc.Name)}
+	interceptor := func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		res, err := handler(ctx, req)
+
+		if err != nil {
+			return res, err
+		}
+
+		data, err := codec.Codec.Marshal(res)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		sum, err := alg.sum(data)
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		grpc.SetTrailer(ctx, metadata.Pairs(ChecksumMetadataKey, string(alg)+":"+sum))
+		return encodedMessage(data), nil
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptor),
+		grpc.ForceServerCodec(codec),
+	}
+}
+
+// ChecksumClientInterceptor returns a client interceptor that verifies the
+// checksum attached by ChecksumServerOptions against the response bytes as
+// received, and fails the call with DataLoss if they don't match. Responses
+// without a checksum are accepted, so that servers can adopt it gradually.
+// The interceptor forces a codec capturing the bytes that delegates to the
+// proto codec, so it overrides grpc.ForceCodec options of the call.
+func ChecksumClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		var trailer metadata.MD
+		codec := &capturingCodec{Codec: encoding.GetCodec(protoenc.Name)}
+		opts = append(opts[:len(opts):len(opts)], grpc.Trailer(&trailer), grpc.ForceCodec(codec))
+
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+
+		values := trailer.Get(ChecksumMetadataKey)
+
+		if len(values) == 0 {
+			return nil
+		}
+
+		alg, want, _ := strings.Cut(values[0], ":")
+		got, err := ChecksumAlgorithm(alg).sum(codec.data)
+
+		if err != nil {
+			return status.Error(codes.DataLoss, err.Error())
+		} else if got != want {
+			return status.Errorf(codes.DataLoss, "grpcasync: %s checksum mismatch in response of %s", alg, method)
+		}
+
+		return nil
+	}
+}
+
+// encodedMessage is a response already serialized by ChecksumServerOptions,
+// which checksumCodec sends as is.
+type encodedMessage []byte
+
+type checksumCodec struct {
+	encoding.Codec
+}
+
+func (c checksumCodec) Marshal(v any) ([]byte, error) {
+	if data, ok := v.(encodedMessage); ok {
+		return data, nil
+	}
+
+	return c.Codec.Marshal(v)
+}
+
+// capturingCodec keeps the bytes of the last message it decoded.
+type capturingCodec struct {
+	encoding.Codec
+	data []byte
+}
+
+func (c *capturingCodec) Unmarshal(data []byte, v any) error {
+	c.data = append(c.data[:0], data...)
+	return c.Codec.Unmarshal(data, v)
+}
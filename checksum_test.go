@@ -0,0 +1,100 @@
+package grpcasync
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// corruptingConn flips a byte of every "World" read from the connection,
+// which alters the response message on the wire without breaking the
+// framing.
+type corruptingConn struct {
+	net.Conn
+}
+
+func (c corruptingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	copy(p[:n], bytes.ReplaceAll(p[:n], []byte("World"), []byte("W0rld")))
+
+	return n, err
+}
+
+func corruptingDialer(lis *bufconn.Listener) grpc.DialOption {
+	return grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		conn, err := lis.DialContext(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return corruptingConn{conn}, nil
+	})
+}
+
+func TestChecksum(t *testing.T) {
+	for _, alg := range []ChecksumAlgorithm{CRC32, SHA256} {
+		t.Run(string(alg), func(t *testing.T) {
+			srv := grpc.NewServer(ChecksumServerOptions(alg)...)
+			examples.RegisterGreeterServer(srv, &greeter{})
+			lis := serve(t, srv)
+
+			tests := []struct {
+				name     string
+				opts     []grpc.DialOption
+				wantCode codes.Code
+			}{
+				{"intact", nil, codes.OK},
+				{"corrupted", []grpc.DialOption{corruptingDialer(lis)}, codes.DataLoss},
+			}
+
+			for _, tt := range tests {
+				opts := append(tt.opts, grpc.WithUnaryInterceptor(ChecksumClientInterceptor()))
+				client := examples.NewGreeterClient(dial(t, lis, opts...))
+				res, err := client.SayHello(context.Background(), &examples.Request{Name: "World"})
+
+				if code := status.Code(err); code != tt.wantCode {
+					t.Errorf("%s: code = %v, want %v (err %v)", tt.name, code, tt.wantCode, err)
+				} else if err == nil && res.Message != "Hello, World" {
+					t.Errorf("%s: message = %q", tt.name, res.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestChecksumServerOptionsStreams(t *testing.T) {
+	// The forced codec must leave streams and requests alone.
+	_, conn := startGreeter(t, &greeter{}, ChecksumServerOptions(CRC32),
+		grpc.WithUnaryInterceptor(ChecksumClientInterceptor()))
+
+	stream, err := conn.NewStream(context.Background(), duplexDesc, duplexMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []*examples.Request{{Name: "World"}}
+	res, err := Exchange[examples.Request, examples.Response](context.Background(), stream, reqs)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res[0].Message != "Hello, World" {
+		t.Errorf("message = %q", res[0].Message)
+	}
+}
+
+func TestChecksumWithoutTrailer(t *testing.T) {
+	client, _ := startGreeter(t, &greeter{}, nil, grpc.WithUnaryInterceptor(ChecksumClientInterceptor()))
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{Name: "World"}); err != nil {
+		t.Errorf("response without a checksum rejected: %v", err)
+	}
+}
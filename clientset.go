@@ -0,0 +1,81 @@
+package grpcasync
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// ClientSet shares one connection between the typed clients of all services
+// a server exposes on the same address. Clients are built on first use from
+// the factories registered with RegisterClient and retrieved with GetClient:
+//
+//	set := grpcasync.NewClientSet(conn)
+//	grpcasync.RegisterClient(set, examples.NewGreeterClient)
+//	greeter, err := grpcasync.GetClient[examples.GreeterClient](set)
+type ClientSet struct {
+	conn *grpc.ClientConn
+
+	mu        sync.Mutex
+	factories map[reflect.Type]func(grpc.ClientConnInterface) any
+	clients   map[reflect.Type]any
+}
+
+// NewClientSet creates a ClientSet over conn.
+func NewClientSet(conn *grpc.ClientConn) *ClientSet {
+	return &ClientSet{
+		conn:      conn,
+		factories: map[reflect.Type]func(grpc.ClientConnInterface) any{},
+		clients:   map[reflect.Type]any{},
+	}
+}
+
+// Conn returns the shared connection.
+func (s *ClientSet) Conn() *grpc.ClientConn {
+	return s.conn
+}
+
+// Close closes the shared connection, and with it all clients of the set.
+func (s *ClientSet) Close() error {
+	return s.conn.Close()
+}
+
+// RegisterClient adds the factory of the client type T, such as a generated
+// NewGreeterClient function, replacing any earlier one for T.
+func RegisterClient[T any](s *ClientSet, factory func(grpc.ClientConnInterface) T) {
+	key := reflect.TypeOf((*T)(nil)).Elem()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.factories[key] = func(cc grpc.ClientConnInterface) any {
+		return factory(cc)
+	}
+	delete(s.clients, key)
+}
+
+// GetClient returns the client of type T, building it on the first call.
+func GetClient[T any](s *ClientSet) (T, error) {
+	key := reflect.TypeOf((*T)(nil)).Elem()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if client, ok := s.clients[key]; ok {
+		return client.(T), nil
+	}
+
+	factory, ok := s.factories[key]
+
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("grpcasync: no client factory registered for %v", key)
+	}
+
+	client := factory(s.conn)
+	s.clients[key] = client
+
+	return client.(T), nil
+}
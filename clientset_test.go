@@ -0,0 +1,50 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+func TestClientSet(t *testing.T) {
+	srv := grpc.NewServer()
+	examples.RegisterGreeterServer(srv, &greeter{})
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+
+	set := NewClientSet(dial(t, serve(t, srv)))
+	RegisterClient(set, examples.NewGreeterClient)
+	RegisterClient(set, healthpb.NewHealthClient)
+
+	greeterClient, err := GetClient[examples.GreeterClient](set)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res, err := greeterClient.SayHello(context.Background(), &examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, World" {
+		t.Errorf("message = %q", res.Message)
+	}
+
+	healthClient, err := GetClient[healthpb.HealthClient](set)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res, err := healthClient.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	} else if res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %v", res.Status)
+	}
+
+	if again, _ := GetClient[examples.GreeterClient](set); again != greeterClient {
+		t.Error("client built again on the second use")
+	}
+
+	if _, err := GetClient[reflectionpb.ServerReflectionClient](set); err == nil {
+		t.Error("no error for a client type without a factory")
+	}
+}
@@ -0,0 +1,99 @@
+package grpcasync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// SendMaybeCompressed sends msg over stream, gzipping its bytes field named
+// field first if the field holds more than threshold bytes. The receiver
+// restores it with DecompressField.
+//
+// gRPC-Go picks the compressor once per stream, before the header is sent,
+// so it cannot compress only some of the messages of a stream. This helper
+// compresses at the application level instead; a compressed field is
+// recognized by the gzip magic number, so uncompressed payloads must not
+// start with the bytes 0x1f 0x8b.
+func SendMaybeCompressed(stream grpc.ServerStream, msg proto.Message, field string, threshold int) error {
+	fd, err := bytesField(msg, field)
+
+	if err != nil {
+		return err
+	}
+
+	m := msg.ProtoReflect()
+	data := m.Get(fd).Bytes()
+
+	if len(data) <= threshold {
+		return stream.SendMsg(msg)
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	} else if err := w.Close(); err != nil {
+		return err
+	}
+
+	// Compress a copy so that the caller's message is left untouched.
+	out := proto.Clone(msg)
+	out.ProtoReflect().Set(fd, protoreflect.ValueOfBytes(buf.Bytes()))
+
+	return stream.SendMsg(out)
+}
+
+// DecompressField reverses SendMaybeCompressed on a received message, it
+// leaves an uncompressed field as is. A field that decompresses to more than
+// maxSize bytes is rejected with an error, which guards against
+// decompression bombs.
+func DecompressField(msg proto.Message, field string, maxSize int64) error {
+	fd, err := bytesField(msg, field)
+
+	if err != nil {
+		return err
+	}
+
+	m := msg.ProtoReflect()
+	data := m.Get(fd).Bytes()
+
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+	plain, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+
+	if err != nil {
+		return err
+	} else if int64(len(plain)) > maxSize {
+		return fmt.Errorf("grpcasync: field %q decompresses to more than %d bytes", field, maxSize)
+	}
+
+	m.Set(fd, protoreflect.ValueOfBytes(plain))
+	return nil
+}
+
+func bytesField(msg proto.Message, field string) (protoreflect.FieldDescriptor, error) {
+	desc := msg.ProtoReflect().Descriptor()
+	fd := desc.Fields().ByName(protoreflect.Name(field))
+
+	if fd == nil || fd.Kind() != protoreflect.BytesKind || fd.IsList() {
+		return nil, fmt.Errorf("grpcasync: %s has no bytes field %q", desc.FullName(), field)
+	}
+
+	return fd, nil
+}
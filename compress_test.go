@@ -0,0 +1,106 @@
+package grpcasync
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// compressingGreeterDesc declares SayHelloStreamReply as streaming
+// google.protobuf.BytesValue, replying with a small and a large payload sent
+// by SendMaybeCompressed with a threshold of 64 bytes.
+var compressingGreeterDesc = grpc.ServiceDesc{
+	ServiceName: "examples.Greeter",
+	HandlerType: (*any)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "SayHelloStreamReply",
+		ServerStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			if err := stream.RecvMsg(new(examples.Request)); err != nil {
+				return err
+			}
+
+			for _, payload := range [][]byte{smallPayload, largePayload} {
+				if err := SendMaybeCompressed(stream, wrapperspb.Bytes(payload), "value", 64); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}},
+}
+
+var (
+	smallPayload = []byte("Hello, World")
+	largePayload = bytes.Repeat([]byte("Hello, World "), 100)
+)
+
+func TestSendMaybeCompressed(t *testing.T) {
+	srv := grpc.NewServer()
+	srv.RegisterService(&compressingGreeterDesc, struct{}{})
+	conn := dial(t, serve(t, srv))
+	stream, err := openStreamReply(context.Background(), conn, "World")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		payload    []byte
+		compressed bool
+	}{
+		{"small", smallPayload, false},
+		{"large", largePayload, true},
+	}
+
+	for _, tt := range tests {
+		msg := new(wrapperspb.BytesValue)
+
+		if err := stream.RecvMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if compressed := bytes.HasPrefix(msg.Value, []byte{0x1f, 0x8b}); compressed != tt.compressed {
+			t.Errorf("%s: compressed = %v, want %v", tt.name, compressed, tt.compressed)
+		} else if compressed && len(msg.Value) >= len(tt.payload) {
+			t.Errorf("%s: sent %d bytes for %d", tt.name, len(msg.Value), len(tt.payload))
+		}
+
+		if err := DecompressField(msg, "value", 1<<20); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(msg.Value, tt.payload) {
+			t.Errorf("%s: decompressed %q", tt.name, msg.Value)
+		}
+	}
+}
+
+func TestDecompressFieldMaxSize(t *testing.T) {
+	var stream capturingStream
+
+	if err := SendMaybeCompressed(&stream, wrapperspb.Bytes(largePayload), "value", 64); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := stream.msgs[0].(*wrapperspb.BytesValue)
+
+	if err := DecompressField(msg, "value", int64(len(largePayload)-1)); err == nil {
+		t.Error("no error for a field decompressing beyond the maximum size")
+	}
+}
+
+// capturingStream is a grpc.ServerStream keeping the messages sent over it.
+type capturingStream struct {
+	grpc.ServerStream
+	msgs []any
+}
+
+func (s *capturingStream) SendMsg(m any) error {
+	s.msgs = append(s.msgs, m)
+	return nil
+}
@@ -0,0 +1,282 @@
+package grpcasync
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// DeadlineMetadataKey is the metadata key used by PropagateDeadline and
+// ApplyPropagatedDeadline, its value is the deadline in Unix milliseconds.
+const DeadlineMetadataKey = "grpc-timeout-unix-ms"
+
+// PropagateDeadline writes the deadline of ctx into the outgoing metadata as
+// an absolute Unix timestamp, so that it survives hops that drop the
+// relative grpc-timeout header. If ctx has no deadline, it is returned as is.
+func PropagateDeadline(ctx context.Context) context.Context {
+	deadline, ok := ctx.Deadline()
+
+	if !ok {
+		return ctx
+	}
+
+	value := strconv.FormatInt(deadline.UnixMilli(), 10)
+	return metadata.AppendToOutgoingContext(ctx, DeadlineMetadataKey, value)
+}
+
+// ApplyPropagatedDeadline reads the deadline written by PropagateDeadline from
+// the incoming metadata and applies it to ctx. If no valid deadline is
+// present, the returned context only adds cancellation.
+func ApplyPropagatedDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(DeadlineMetadataKey)
+
+	if len(values) == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	ms, err := strconv.ParseInt(values[0], 10, 64)
+
+	if err != nil {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadline(ctx, time.UnixMilli(ms))
+}
+
+// SplitDeadline derives a context that gets 1/n of the time remaining until
+// the deadline of ctx, so that each of n sequential downstream calls has a
+// fair share of the budget. If ctx has no deadline or n < 1, the returned
+// context only adds cancellation.
+func SplitDeadline(ctx context.Context, n int) (context.Context, context.CancelFunc) {
+	if n < 1 {
+		return context.WithCancel(ctx)
+	}
+
+	return WithBudget(ctx, 1/float64(n))
+}
+
+// WithBudget derives a context whose deadline is the given fraction of the
+// time remaining until the deadline of ctx. If ctx has no deadline or
+// fraction is not in (0, 1], the returned context only adds cancellation.
+func WithBudget(ctx context.Context, fraction float64) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+
+	if !ok || fraction <= 0 || fraction > 1 {
+		return context.WithCancel(ctx)
+	}
+
+	remaining := time.Until(deadline)
+	return context.WithTimeout(ctx, time.Duration(float64(remaining)*fraction))
+}
+
+// WithGlobalTimeout returns dial options installing interceptors that bound
+// every call made without a deadline to d, as a safety net against calls
+// that would otherwise wait forever. Calls that already have a deadline are
+// left alone.
+//
+// For streams, d bounds the whole lifetime of the stream from the moment it
+// is opened, not the wait for each message.
+func WithGlobalTimeout(d time.Duration) []grpc.DialOption {
+	unary := func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	stream := func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		if _, ok := ctx.Deadline(); ok {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, d)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		return &cancelOnEndStream{stream, cancel}, nil
+	}
+
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary),
+		grpc.WithChainStreamInterceptor(stream),
+	}
+}
+
+// cancelOnEndStream releases the context of a stream once it has ended, which
+// RecvMsg reports by returning an error.
+type cancelOnEndStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+func (s *cancelOnEndStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+
+	if err != nil {
+		s.cancel()
+	}
+
+	return err
+}
+
+// DeadlineFromFieldInterceptor returns a server interceptor that applies the
+// timeout carried in the named integer field of the request, in
+// milliseconds, as the deadline of the handler context, e.g. for a
+// "timeout_ms" field. Requests that lack the field or leave it at zero, and
+// non-proto requests, are handled without a further deadline. The timeout can
+// only shorten, never extend, the deadline set by the client.
+func DeadlineFromFieldInterceptor(field string) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if ms := intField(req, field); ms > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+			defer cancel()
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func intField(msg any, field string) int64 {
+	m, ok := msg.(proto.Message)
+
+	if !ok {
+		return 0
+	}
+
+	r := m.ProtoReflect()
+	fd := r.Descriptor().Fields().ByName(protoreflect.Name(field))
+
+	if fd == nil || fd.IsList() || fd.IsMap() {
+		return 0
+	}
+
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return r.Get(fd).Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return int64(r.Get(fd).Uint())
+	default:
+		return 0
+	}
+}
+
+// EffectiveDeadlineMetadataKey is the header set by EchoDeadlineInterceptor,
+// its value is the deadline in Unix milliseconds.
+const EffectiveDeadlineMetadataKey = "grpc-effective-deadline-ms"
+
+// EchoDeadlineInterceptor returns a server interceptor that reports the
+// deadline the server sees for a unary call back to the client in a header,
+// which helps diagnosing deadlines lost or altered on the way. Clients can
+// read it with CallWithMeta. Calls without a deadline get no header.
+func EchoDeadlineInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if deadline, ok := ctx.Deadline(); ok {
+			value := strconv.FormatInt(deadline.UnixMilli(), 10)
+			grpc.SetHeader(ctx, metadata.Pairs(EffectiveDeadlineMetadataKey, value))
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// TimeoutsFromDescriptor reads the default timeouts declared on the methods
+// of a service through the custom method option ext and returns them keyed by
+// full method name, e.g. "/examples.Greeter/SayHello". The option may be a
+// google.protobuf.Duration, a string accepted by time.ParseDuration or an
+// integer number of milliseconds. Methods without the option are omitted.
+//
+// The service must be compiled into the binary, so that its descriptor is
+// found in the global registry.
+func TimeoutsFromDescriptor(sd grpc.ServiceDesc, ext protoreflect.ExtensionType) (map[string]time.Duration, error) {
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(sd.ServiceName))
+
+	if err != nil {
+		return nil, err
+	}
+
+	service, ok := desc.(protoreflect.ServiceDescriptor)
+
+	if !ok {
+		return nil, fmt.Errorf("grpcasync: %s is not a service", sd.ServiceName)
+	}
+
+	timeouts := map[string]time.Duration{}
+	methods := service.Methods()
+
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		opts := method.Options()
+
+		if opts == nil || !proto.HasExtension(opts, ext) {
+			continue
+		}
+
+		var d time.Duration
+
+		switch v := proto.GetExtension(opts, ext).(type) {
+		case *durationpb.Duration:
+			d = v.AsDuration()
+		case string:
+			if d, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("grpcasync: timeout of %s: %w", method.FullName(), err)
+			}
+		case int32:
+			d = time.Duration(v) * time.Millisecond
+		case int64:
+			d = time.Duration(v) * time.Millisecond
+		case uint32:
+			d = time.Duration(v) * time.Millisecond
+		case uint64:
+			d = time.Duration(v) * time.Millisecond
+		default:
+			return nil, fmt.Errorf("grpcasync: unsupported timeout option type %T", v)
+		}
+
+		timeouts["/"+sd.ServiceName+"/"+string(method.Name())] = d
+	}
+
+	return timeouts, nil
+}
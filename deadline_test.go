@@ -0,0 +1,427 @@
+package grpcasync
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestPropagateDeadline(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		// Only keep the metadata so that the deadline set by gRPC itself
+		// does not get in the way.
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx, cancel := ApplyPropagatedDeadline(metadata.NewIncomingContext(context.Background(), md))
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		return &examples.Response{}, nil
+	}}, nil)
+
+	want := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	ctx, cancel := context.WithDeadline(context.Background(), want)
+	defer cancel()
+
+	if _, err := client.SayHello(PropagateDeadline(ctx), &examples.Request{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-deadlines; !got.Equal(want) {
+		t.Errorf("server deadline = %v, want %v", got, want)
+	}
+}
+
+func TestApplyPropagatedDeadlineWithout(t *testing.T) {
+	ctx, cancel := ApplyPropagatedDeadline(context.Background())
+	defer cancel()
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("context has a deadline without the metadata")
+	}
+}
+
+func TestSplitDeadline(t *testing.T) {
+	remaining := make(chan time.Duration, 1)
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		deadline, _ := ctx.Deadline()
+		remaining <- time.Until(deadline)
+		return &examples.Response{}, nil
+	}}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	split, cancelSplit := SplitDeadline(ctx, 4)
+	defer cancelSplit()
+
+	if _, err := client.SayHello(split, &examples.Request{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-remaining; got > time.Second || got < 900*time.Millisecond {
+		t.Errorf("remaining time at the server = %v, want about 1s", got)
+	}
+}
+
+func TestWithBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		fraction float64
+		want     time.Duration
+	}{
+		{"half", 0.5, 500 * time.Millisecond},
+		{"whole", 1, time.Second},
+		{"zero", 0, time.Second},
+		{"too large", 2, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget, cancel := WithBudget(ctx, tt.fraction)
+			defer cancel()
+
+			deadline, _ := budget.Deadline()
+
+			if got := time.Until(deadline); got > tt.want || got < tt.want-100*time.Millisecond {
+				t.Errorf("remaining = %v, want about %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitDeadlineWithout(t *testing.T) {
+	ctx, cancel := SplitDeadline(context.Background(), 2)
+	defer cancel()
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("split context has a deadline without a parent deadline")
+	}
+}
+
+func TestWithGlobalTimeout(t *testing.T) {
+	client, conn := startGreeter(t, &sleepyGreeter{}, nil, WithGlobalTimeout(50*time.Millisecond)...)
+
+	start := time.Now()
+	_, err := client.SayHello(context.Background(), &examples.Request{Name: "2s"})
+
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("call failed after %v, not bound by the global timeout", elapsed)
+	}
+
+	// A deadline of the caller takes precedence.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.SayHello(ctx, &examples.Request{Name: "100ms"}); err != nil {
+		t.Errorf("call with its own deadline failed: %v", err)
+	}
+
+	stream, err := openStreamReply(context.Background(), conn, "2s")
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err := stream.RecvMsg(new(examples.Response)); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("stream err = %v, want DeadlineExceeded", err)
+	}
+}
+
+// timedRequestDesc describes a Greeter request that also carries a timeout:
+//
+//	message TimedRequest {
+//		string name = 1;
+//		int64 timeout_ms = 2;
+//	}
+var timedRequestDesc = func() protoreflect.MessageDescriptor {
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("timed_request.proto"),
+		Package: proto.String("grpcasync.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("TimedRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:   proto.String("name"),
+				Number: proto.Int32(1),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}, {
+				Name:   proto.String("timeout_ms"),
+				Number: proto.Int32(2),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}},
+		}},
+	}, nil)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return file.Messages().Get(0)
+}()
+
+func newTimedRequest(timeoutMs int64) *dynamicpb.Message {
+	req := dynamicpb.NewMessage(timedRequestDesc)
+	req.Set(timedRequestDesc.Fields().ByName("timeout_ms"), protoreflect.ValueOfInt64(timeoutMs))
+
+	return req
+}
+
+func TestDeadlineFromFieldInterceptor(t *testing.T) {
+	remaining := make(chan time.Duration, 1)
+
+	// SayHello decodes its request as a TimedRequest, to which the
+	// interceptor applies.
+	desc := grpc.ServiceDesc{
+		ServiceName: "examples.Greeter",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "SayHello",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := dynamicpb.NewMessage(timedRequestDesc)
+
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+
+				info := &grpc.UnaryServerInfo{FullMethod: sayHelloMethod}
+				return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					if deadline, ok := ctx.Deadline(); ok {
+						remaining <- time.Until(deadline)
+					} else {
+						remaining <- 0
+					}
+
+					return &examples.Response{}, nil
+				})
+			},
+		}},
+	}
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(DeadlineFromFieldInterceptor("timeout_ms")))
+	srv.RegisterService(&desc, struct{}{})
+	conn := dial(t, serve(t, srv))
+
+	// The deadline of the client is far off, so the field alone sets it.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tests := []struct {
+		name      string
+		timeoutMs int64
+		min, max  time.Duration
+	}{
+		{"field", 50, 0, 50 * time.Millisecond},
+		{"zero", 0, 50 * time.Second, time.Minute},
+		{"longer than the client's", 3600_000, 50 * time.Second, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if err := conn.Invoke(ctx, sayHelloMethod, newTimedRequest(tt.timeoutMs), new(examples.Response)); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := <-remaining; got <= tt.min || got > tt.max {
+			t.Errorf("%s: remaining = %v, want in (%v, %v]", tt.name, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestEchoDeadlineInterceptor(t *testing.T) {
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(EchoDeadlineInterceptor()),
+	})
+
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	_, header, _, err := CallWithMeta(func(opts ...grpc.CallOption) (*examples.Response, error) {
+		return client.SayHello(ctx, &examples.Request{}, opts...)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := header.Get(EffectiveDeadlineMetadataKey)
+
+	if len(values) != 1 {
+		t.Fatalf("%s = %v, want one value", EffectiveDeadlineMetadataKey, values)
+	}
+
+	ms, err := strconv.ParseInt(values[0], 10, 64)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The deadline travels as a relative timeout, rounded up by the client and
+	// restarted by the server, which shifts it a little either way.
+	if diff := deadline.Sub(time.UnixMilli(ms)); diff < -10*time.Millisecond || diff > 100*time.Millisecond {
+		t.Errorf("echoed deadline is %v off the client's", diff)
+	}
+
+	_, header, _, err = CallWithMeta(func(opts ...grpc.CallOption) (*examples.Response, error) {
+		return client.SayHello(context.Background(), &examples.Request{}, opts...)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if values := header.Get(EffectiveDeadlineMetadataKey); len(values) != 0 {
+		t.Errorf("header %v set without a deadline", values)
+	}
+}
+
+// timeoutOption and timeoutMsOption are the custom method options of
+// timed_greeter.proto, which declares the defaults of its TimedGreeter:
+//
+//	extend google.protobuf.MethodOptions {
+//		string timeout = 50001;
+//		int64 timeout_ms = 50002;
+//	}
+//
+//	service TimedGreeter {
+//		rpc SayHello(Empty) returns (Empty) {
+//			option (timeout) = "250ms";
+//			option (timeout_ms) = 100;
+//		}
+//		rpc SayHelloStreamReply(Empty) returns (stream Empty) {
+//			option (timeout_ms) = 2000;
+//		}
+//		rpc SayHelloDuplex(stream Empty) returns (stream Empty);
+//	}
+var timeoutOption, timeoutMsOption = func() (protoreflect.ExtensionType, protoreflect.ExtensionType) {
+	options, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("timed_greeter_options.proto"),
+		Package:    proto.String("grpcasync.test"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Extension: []*descriptorpb.FieldDescriptorProto{{
+			Name:     proto.String("timeout"),
+			Number:   proto.Int32(50001),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Extendee: proto.String(".google.protobuf.MethodOptions"),
+		}, {
+			Name:     proto.String("timeout_ms"),
+			Number:   proto.Int32(50002),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Extendee: proto.String(".google.protobuf.MethodOptions"),
+		}},
+	}, protoregistry.GlobalFiles)
+
+	if err != nil {
+		panic(err)
+	} else if err := protoregistry.GlobalFiles.RegisterFile(options); err != nil {
+		panic(err)
+	}
+
+	timeout := dynamicpb.NewExtensionType(options.Extensions().Get(0))
+	timeoutMs := dynamicpb.NewExtensionType(options.Extensions().Get(1))
+
+	sayHello := &descriptorpb.MethodOptions{}
+	proto.SetExtension(sayHello, timeout, "250ms")
+	proto.SetExtension(sayHello, timeoutMs, int64(100))
+
+	streamReply := &descriptorpb.MethodOptions{}
+	proto.SetExtension(streamReply, timeoutMs, int64(2000))
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("timed_greeter.proto"),
+		Package:     proto.String("grpcasync.test"),
+		Syntax:      proto.String("proto3"),
+		Dependency:  []string{"timed_greeter_options.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("TimedGreeter"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("SayHello"),
+				InputType:  proto.String(".grpcasync.test.Empty"),
+				OutputType: proto.String(".grpcasync.test.Empty"),
+				Options:    sayHello,
+			}, {
+				Name:            proto.String("SayHelloStreamReply"),
+				InputType:       proto.String(".grpcasync.test.Empty"),
+				OutputType:      proto.String(".grpcasync.test.Empty"),
+				ServerStreaming: proto.Bool(true),
+				Options:         streamReply,
+			}, {
+				Name:            proto.String("SayHelloDuplex"),
+				InputType:       proto.String(".grpcasync.test.Empty"),
+				OutputType:      proto.String(".grpcasync.test.Empty"),
+				ClientStreaming: proto.Bool(true),
+				ServerStreaming: proto.Bool(true),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+
+	if err != nil {
+		panic(err)
+	}
+
+	// TimeoutsFromDescriptor looks the service up in the global registry,
+	// as it would a compiled one.
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+
+	return timeout, timeoutMs
+}()
+
+func TestTimeoutsFromDescriptor(t *testing.T) {
+	sd := grpc.ServiceDesc{ServiceName: "grpcasync.test.TimedGreeter"}
+	tests := []struct {
+		name string
+		ext  protoreflect.ExtensionType
+		want map[string]time.Duration
+	}{
+		{"string", timeoutOption, map[string]time.Duration{
+			"/grpcasync.test.TimedGreeter/SayHello": 250 * time.Millisecond,
+		}},
+		{"milliseconds", timeoutMsOption, map[string]time.Duration{
+			"/grpcasync.test.TimedGreeter/SayHello":            100 * time.Millisecond,
+			"/grpcasync.test.TimedGreeter/SayHelloStreamReply": 2 * time.Second,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeouts, err := TimeoutsFromDescriptor(sd, tt.ext)
+
+			if err != nil {
+				t.Fatal(err)
+			} else if !reflect.DeepEqual(timeouts, tt.want) {
+				t.Errorf("timeouts = %v, want %v", timeouts, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutsFromDescriptorUnknownService(t *testing.T) {
+	sd := grpc.ServiceDesc{ServiceName: "grpcasync.test.Missing"}
+
+	if _, err := TimeoutsFromDescriptor(sd, timeoutOption); err == nil {
+		t.Error("got no error for a service missing from the registry")
+	}
+}
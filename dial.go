@@ -0,0 +1,95 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ConnectMany connects to all targets with at most concurrency connection
+// attempts in flight, so that connecting to many backends at startup does not
+// flood the network, and returns the connections that became ready, keyed by
+// target, along with the errors of those that failed joined together. Each
+// attempt lasts until its connection is ready or fails for the first time,
+// and ctx bounds them all. Targets listed more than once are connected once.
+func ConnectMany(
+	ctx context.Context,
+	targets []string,
+	concurrency int,
+	opts ...grpc.DialOption,
+) (map[string]*grpc.ClientConn, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+
+	conns := make(map[string]*grpc.ClientConn, len(targets))
+	sem := make(chan struct{}, max(concurrency, 1))
+
+	seen := make(map[string]bool, len(targets))
+
+	for _, addr := range targets {
+		if seen[addr] {
+			continue
+		}
+
+		seen[addr] = true
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(addr string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			conn, err := connect(ctx, addr, opts...)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				conns[addr] = conn
+			}
+		}(addr)
+	}
+
+	wg.Wait()
+	return conns, errors.Join(errs...)
+}
+
+// connect dials addr and waits for the connection to become ready, closing it
+// again if it fails first.
+func connect(ctx context.Context, addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	target, err := NormalizeTarget(addr)
+
+	if err != nil {
+		return nil, fmt.Errorf("grpcasync: dial %s: %w", addr, err)
+	}
+
+	conn, err := grpc.DialContext(ctx, target, opts...)
+
+	if err != nil {
+		return nil, fmt.Errorf("grpcasync: dial %s: %w", addr, err)
+	}
+
+	conn.Connect()
+
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			err = fmt.Errorf("grpcasync: dial %s: connection %v", addr, state)
+		} else if !conn.WaitForStateChange(ctx, state) {
+			err = fmt.Errorf("grpcasync: dial %s: %w", addr, ctx.Err())
+		}
+
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,103 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestConnectMany(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	listeners := map[string]*bufconn.Listener{}
+
+	for _, name := range names {
+		srv := grpc.NewServer()
+		examples.RegisterGreeterServer(srv, &namedGreeter{name: name})
+		listeners[name] = serve(t, srv)
+	}
+
+	var mu sync.Mutex
+	dialing, peak, dials := 0, 0, 0
+
+	// The dialer takes a while, so that dials overlap as much as the
+	// concurrency allows.
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		mu.Lock()
+		dialing++
+		dials++
+		peak = max(peak, dialing)
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			dialing--
+			mu.Unlock()
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+
+		if lis, ok := listeners[addr]; ok {
+			return lis.DialContext(ctx)
+		}
+
+		return nil, errors.New("no such backend")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Without grpc.WithBlock, the attempts are still bounded, and a target
+	// listed twice is only connected once.
+	targets := []string{
+		"passthrough:///a", "passthrough:///b", "bogus:///e", "passthrough:///c", "passthrough:///d", "passthrough:///a",
+		"passthrough:///f",
+	}
+	conns, err := ConnectMany(ctx, targets, 2,
+		dialer,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	// The connection to the missing backend f is created, but fails.
+	if err == nil || !strings.Contains(err.Error(), "bogus:///e") || !strings.Contains(err.Error(), "passthrough:///f") {
+		t.Errorf("err = %v, want the failures of bogus:///e and passthrough:///f", err)
+	}
+
+	if len(conns) != len(names) {
+		t.Fatalf("got %d connections, want %d", len(conns), len(names))
+	}
+
+	for _, name := range names {
+		conn := conns["passthrough:///"+name]
+
+		if conn == nil {
+			t.Errorf("no connection to %s", name)
+			continue
+		}
+
+		defer conn.Close()
+
+		if res, err := examples.NewGreeterClient(conn).SayHello(ctx, &examples.Request{}); err != nil {
+			t.Error(err)
+		} else if res.Message != name {
+			t.Errorf("connection to %s reached %s", name, res.Message)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if peak != 2 {
+		t.Errorf("%d dials in flight at once, want 2", peak)
+	} else if dials != len(names)+1 {
+		t.Errorf("%d dials, want one per backend and one for f", dials)
+	}
+}
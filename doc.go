@@ -0,0 +1,8 @@
+// Package grpcasync provides small helpers for gRPC-Go that smooth over the
+// callback-heavy and stream-oriented parts of the API, the same way the
+// Node.js side of this project does for @grpc/grpc-js.
+//
+// Generic helpers take the message struct type as their type parameter, e.g.
+// TakeN[examples.Response], and return pointers to freshly allocated messages,
+// matching the generated Recv methods.
+package grpcasync
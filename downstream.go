@@ -0,0 +1,78 @@
+package grpcasync
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// DownstreamClient is a grpc.ClientConnInterface for calling other services
+// from within a handler. Every call it makes is bound to the handler's
+// context, so when the inbound call is cancelled or times out, in-flight
+// downstream calls are cancelled too, even if they were started with an
+// unrelated context such as one created for a background task.
+//
+// Passing the handler's ctx to downstream calls directly gives the same
+// cancellation, DownstreamClient is for code paths where that context is not
+// at hand.
+type DownstreamClient struct {
+	conn   grpc.ClientConnInterface
+	parent context.Context
+}
+
+// NewDownstreamClient binds conn to the handler context parent.
+func NewDownstreamClient(conn grpc.ClientConnInterface, parent context.Context) *DownstreamClient {
+	return &DownstreamClient{conn: conn, parent: parent}
+}
+
+// Invoke implements grpc.ClientConnInterface. If the call fails because the
+// inbound call has ended, the error reports that as a Canceled or
+// DeadlineExceeded status.
+func (c *DownstreamClient) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	ctx, cancel := c.bind(ctx)
+	defer cancel()
+
+	if err := c.conn.Invoke(ctx, method, args, reply, opts...); err != nil {
+		return c.convert(err)
+	}
+
+	return nil
+}
+
+// NewStream implements grpc.ClientConnInterface. The stream is cancelled
+// with the inbound call, and no longer tied to it once it has ended.
+func (c *DownstreamClient) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	ctx, cancel := c.bind(ctx)
+	stream, err := c.conn.NewStream(ctx, desc, method, opts...)
+
+	if err != nil {
+		cancel()
+		return nil, c.convert(err)
+	}
+
+	return &cancelOnEndStream{stream, cancel}, nil
+}
+
+func (c *DownstreamClient) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.parent, cancel)
+
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (c *DownstreamClient) convert(err error) error {
+	if cause := c.parent.Err(); cause != nil {
+		return status.FromContextError(cause).Err()
+	}
+
+	return err
+}
@@ -0,0 +1,81 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDownstreamClient(t *testing.T) {
+	started := make(chan struct{})
+	downstreamDone := make(chan error, 1)
+	_, backend := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		close(started)
+		<-ctx.Done()
+		downstreamDone <- ctx.Err()
+		return nil, ctx.Err()
+	}}, nil)
+
+	frontDone := make(chan error, 1)
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		downstream := examples.NewGreeterClient(NewDownstreamClient(backend, ctx))
+
+		// An unrelated context, only the binding can cancel the call.
+		_, err := downstream.SayHello(context.Background(), req)
+		frontDone <- err
+		return nil, err
+	}}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	if _, err := client.SayHello(ctx, &examples.Request{Name: "World"}); status.Code(err) != codes.Canceled {
+		t.Errorf("inbound err = %v, want Canceled", err)
+	}
+
+	select {
+	case err := <-downstreamDone:
+		if err != context.Canceled {
+			t.Errorf("downstream ctx err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("downstream call not cancelled")
+	}
+
+	if err := <-frontDone; status.Code(err) != codes.Canceled {
+		t.Errorf("downstream call err = %v, want Canceled", err)
+	}
+}
+
+func TestDownstreamClientStreamEnds(t *testing.T) {
+	_, backend := startGreeter(t, &greeter{}, nil)
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := examples.NewGreeterClient(NewDownstreamClient(backend, parent)).
+		SayHelloStreamReply(context.Background(), &examples.Request{Name: "World"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		if _, err := stream.Recv(); err != nil {
+			break
+		}
+	}
+
+	// The stream has let go of its context, while the parent lives on.
+	if stream.Context().Err() == nil {
+		t.Error("stream context still alive after the stream ended")
+	} else if parent.Err() != nil {
+		t.Error("parent canceled by the stream")
+	}
+}
@@ -0,0 +1,475 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// OrderedDuplex serves a bidirectional stream by calling handle for each
+// request, running up to concurrency handlers at once while still sending the
+// responses in the order the requests arrived. The first error returned by
+// handle, receiving or sending ends the stream and is returned, so it can be
+// returned from the method implementation directly.
+func OrderedDuplex[Req any, Res any](
+	stream grpc.ServerStream,
+	handle func(ctx context.Context, req *Req) (*Res, error),
+	concurrency int,
+) error {
+	type result struct {
+		res *Res
+		err error
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	// Every queued channel is either being awaited by the sender or buffered,
+	// which bounds the number of running handlers to concurrency.
+	queue := make(chan chan result, concurrency-1)
+	sent := make(chan error, 1)
+
+	go func() {
+		for pending := range queue {
+			r := <-pending
+
+			if r.err == nil {
+				r.err = stream.SendMsg(r.res)
+			}
+
+			if r.err != nil {
+				cancel()
+				sent <- r.err
+
+				// Drain the queue so that the receiver never blocks.
+				for range queue {
+				}
+
+				return
+			}
+		}
+
+		sent <- nil
+	}()
+
+	// Receive in a goroutine of its own, so that a failed handler or send
+	// ends the stream without waiting for the next request.
+	reqs := make(chan *Req)
+	received := make(chan error, 1)
+
+	go func() {
+		for {
+			req := new(Req)
+
+			if err := stream.RecvMsg(req); err != nil {
+				received <- err
+				return
+			}
+
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case req := <-reqs:
+			pending := make(chan result, 1)
+
+			select {
+			case queue <- pending:
+			case <-ctx.Done():
+				close(queue)
+				return <-sent
+			}
+
+			go func() {
+				res, err := handle(ctx, req)
+				pending <- result{res, err}
+			}()
+		case err := <-received:
+			if err == io.EOF {
+				close(queue)
+				return <-sent
+			}
+
+			cancel()
+			close(queue)
+			<-sent
+			return err
+		case err := <-sent:
+			// The sender only reports before the queue is closed if it
+			// failed.
+			close(queue)
+			return err
+		}
+	}
+}
+
+// AckingDuplex is the client side of a resumable bidirectional stream. Each
+// request sent through it is stamped with a sequence number and kept until
+// the server acknowledges that number in a response. After a disconnect,
+// attaching a new stream resends every unacknowledged request in order, which
+// gives at-least-once delivery, so the server must tolerate duplicates.
+//
+// Send and Recv may be called from different goroutines, but each of them
+// only from one goroutine at a time.
+type AckingDuplex[Req any, Res any] struct {
+	setSeq func(req *Req, seq uint64)
+	ackOf  func(res *Res) (seq uint64, ok bool)
+
+	// sendMu keeps sends in sequence order, mu guards the state below and is
+	// never held while sending, so that a blocked send doesn't hold up Recv.
+	sendMu  sync.Mutex
+	mu      sync.Mutex
+	stream  grpc.ClientStream
+	next    uint64
+	seqs    []uint64
+	unacked map[uint64]*Req
+}
+
+// NewAckingDuplex creates an AckingDuplex. setSeq writes the sequence number
+// into a request, ackOf reads the acknowledged sequence number from a
+// response and reports false for responses that acknowledge nothing.
+func NewAckingDuplex[Req any, Res any](
+	setSeq func(req *Req, seq uint64),
+	ackOf func(res *Res) (seq uint64, ok bool),
+) *AckingDuplex[Req, Res] {
+	return &AckingDuplex[Req, Res]{
+		setSeq:  setSeq,
+		ackOf:   ackOf,
+		next:    1,
+		unacked: map[uint64]*Req{},
+	}
+}
+
+// Attach switches to stream, typically a newly opened one after the previous
+// stream failed, and resends all unacknowledged requests over it.
+func (d *AckingDuplex[Req, Res]) Attach(stream grpc.ClientStream) error {
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+
+	d.mu.Lock()
+	d.stream = stream
+	reqs := make([]*Req, len(d.seqs))
+
+	for i, seq := range d.seqs {
+		reqs[i] = d.unacked[seq]
+	}
+
+	d.mu.Unlock()
+
+	for _, req := range reqs {
+		if err := stream.SendMsg(req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Send stamps req with the next sequence number, records it as unacknowledged
+// and sends it over the current stream.
+func (d *AckingDuplex[Req, Res]) Send(req *Req) error {
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+
+	d.mu.Lock()
+	seq := d.next
+	d.next++
+	d.setSeq(req, seq)
+	d.seqs = append(d.seqs, seq)
+	d.unacked[seq] = req
+	stream := d.stream
+	d.mu.Unlock()
+
+	if stream == nil {
+		return nil
+	}
+
+	return stream.SendMsg(req)
+}
+
+// Recv receives the next response from the current stream and marks the
+// request it acknowledges, if any, as delivered.
+func (d *AckingDuplex[Req, Res]) Recv() (*Res, error) {
+	d.mu.Lock()
+	stream := d.stream
+	d.mu.Unlock()
+
+	if stream == nil {
+		return nil, errors.New("grpcasync: no stream attached")
+	}
+
+	res := new(Res)
+
+	if err := stream.RecvMsg(res); err != nil {
+		return nil, err
+	}
+
+	if seq, ok := d.ackOf(res); ok {
+		d.mu.Lock()
+
+		if _, pending := d.unacked[seq]; pending {
+			delete(d.unacked, seq)
+			d.seqs = slices.DeleteFunc(d.seqs, func(s uint64) bool { return s == seq })
+		}
+
+		d.mu.Unlock()
+	}
+
+	return res, nil
+}
+
+// Unacked returns the requests that have not been acknowledged yet, in the
+// order they were sent.
+func (d *AckingDuplex[Req, Res]) Unacked() []*Req {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reqs := make([]*Req, len(d.seqs))
+
+	for i, seq := range d.seqs {
+		reqs[i] = d.unacked[seq]
+	}
+
+	return reqs
+}
+
+// MultiplexDuplex carries several independent logical channels over one
+// bidirectional client stream. Outgoing messages are tagged with the ID of
+// their channel, and incoming ones are dispatched by their ID to the channel
+// that opened it; messages for unknown IDs are dropped.
+//
+// Closing the send side of a logical channel sends an end marker for its ID,
+// telling the server that no more requests follow on it. The receive side is
+// closed once the server answers with an end marker of its own, after which
+// the ID can be opened again. CloseSend half-closes the whole stream. All
+// receive sides are closed when the stream ends, after which Err reports why.
+// A receive side that is not drained stalls all others.
+type MultiplexDuplex[Req any, Res any] struct {
+	stream grpc.ClientStream
+	setID  func(req *Req, id string, end bool)
+	idOf   func(res *Res) (id string, end bool)
+	pool   *Pool
+
+	mu      sync.Mutex
+	sendMu  sync.Mutex
+	chans   map[string]chan *Res
+	sending map[string]bool
+	closing bool
+	ended   bool
+	err     error
+}
+
+// NewMultiplexDuplex starts dispatching the responses of stream. setID tags a
+// request with a channel ID, or turns it into the end marker of the channel
+// if end is true; idOf reads the ID of a response and whether it is the end
+// marker of the channel.
+func NewMultiplexDuplex[Req any, Res any](
+	stream grpc.ClientStream,
+	setID func(req *Req, id string, end bool),
+	idOf func(res *Res) (id string, end bool),
+	opts ...StreamOption,
+) *MultiplexDuplex[Req, Res] {
+	m := &MultiplexDuplex[Req, Res]{
+		stream:  stream,
+		setID:   setID,
+		idOf:    idOf,
+		pool:    workerPool(opts),
+		chans:   map[string]chan *Res{},
+		sending: map[string]bool{},
+	}
+
+	// Without a worker there is nothing to dispatch, so the duplex ends
+	// right away with the reason.
+	if err := m.pool.GoCtx(stream.Context(), m.dispatch); err != nil {
+		m.err = err
+		m.ended = true
+	}
+
+	return m
+}
+
+// OpenChannel opens the logical channel id and returns its send and receive
+// sides. It returns nil channels if either side of id is still open, if the
+// stream is being half-closed, or if the stream ends while waiting for a
+// worker of the pool.
+func (m *MultiplexDuplex[Req, Res]) OpenChannel(id string) (chan<- *Req, <-chan *Res) {
+	m.mu.Lock()
+
+	if _, ok := m.chans[id]; ok || m.sending[id] || m.closing || m.ended {
+		m.mu.Unlock()
+		return nil, nil
+	}
+
+	in := make(chan *Res, 16)
+	out := make(chan *Req)
+	m.chans[id] = in
+	m.sending[id] = true
+	m.mu.Unlock()
+
+	// The lock is released first, as waiting for a worker of the pool may
+	// take until another channel, which needs the lock, is done.
+	err := m.pool.GoCtx(m.stream.Context(), func() { m.forward(id, out) })
+
+	if err != nil {
+		// The stream has ended meanwhile, which may have closed in already.
+		m.mu.Lock()
+
+		if m.chans[id] == in {
+			close(in)
+			delete(m.chans, id)
+		}
+
+		delete(m.sending, id)
+		m.mu.Unlock()
+		m.closeSendIfIdle()
+
+		return nil, nil
+	}
+
+	return out, in
+}
+
+// CloseSend half-closes the stream once the send sides of all channels are
+// closed, no more channels can be opened from then on.
+func (m *MultiplexDuplex[Req, Res]) CloseSend() {
+	m.mu.Lock()
+	m.closing = true
+	m.mu.Unlock()
+
+	m.closeSendIfIdle()
+}
+
+// Err returns the error that ended the stream, or nil if it ended normally or
+// is still running.
+func (m *MultiplexDuplex[Req, Res]) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.err
+}
+
+func (m *MultiplexDuplex[Req, Res]) forward(id string, out <-chan *Req) {
+	send := func(req *Req, end bool) error {
+		m.setID(req, id, end)
+		m.sendMu.Lock()
+		defer m.sendMu.Unlock()
+
+		return m.stream.SendMsg(req)
+	}
+
+	var err error
+
+	for req := range out {
+		if err = send(req, false); err != nil {
+			// The stream is broken, dispatch reports the actual error. Keep
+			// draining so that senders don't block.
+			for range out {
+			}
+
+			break
+		}
+	}
+
+	// The send side is released before the end marker goes out, as the ID
+	// may be opened again as soon as the server answers it. Holding sendMu
+	// meanwhile keeps the requests of the reopened channel behind the marker.
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
+
+	m.mu.Lock()
+	delete(m.sending, id)
+	idle := m.idle()
+	m.mu.Unlock()
+
+	if err == nil {
+		end := new(Req)
+		m.setID(end, id, true)
+		m.stream.SendMsg(end)
+	}
+
+	if idle {
+		m.stream.CloseSend()
+	}
+}
+
+// closeSendIfIdle half-closes the stream if CloseSend was called and no send
+// side is left open.
+func (m *MultiplexDuplex[Req, Res]) closeSendIfIdle() {
+	m.mu.Lock()
+	idle := m.idle()
+	m.mu.Unlock()
+
+	if idle {
+		m.sendMu.Lock()
+		defer m.sendMu.Unlock()
+
+		m.stream.CloseSend()
+	}
+}
+
+// idle reports whether the stream is to be half-closed now, because CloseSend
+// was called and no send side is left open, and marks it as ended if so. m.mu
+// must be held, the caller half-closes the stream holding m.sendMu instead.
+func (m *MultiplexDuplex[Req, Res]) idle() bool {
+	if m.closing && !m.ended && len(m.sending) == 0 {
+		m.ended = true
+		return true
+	}
+
+	return false
+}
+
+func (m *MultiplexDuplex[Req, Res]) dispatch() {
+	for {
+		res := new(Res)
+		err := m.stream.RecvMsg(res)
+
+		if err != nil {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			if err != io.EOF {
+				m.err = err
+			}
+
+			for _, in := range m.chans {
+				close(in)
+			}
+
+			m.chans = map[string]chan *Res{}
+			m.ended = true
+
+			return
+		}
+
+		id, end := m.idOf(res)
+
+		m.mu.Lock()
+		in, ok := m.chans[id]
+
+		if ok && end {
+			close(in)
+			delete(m.chans, id)
+		}
+
+		m.mu.Unlock()
+
+		if ok && !end {
+			in <- res
+		}
+	}
+}
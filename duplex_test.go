@@ -0,0 +1,369 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// orderedGreeter serves SayHelloDuplex with OrderedDuplex, each handler
+// sleeping for as many 10ms as the request name says, and failing for "fail".
+type orderedGreeter struct {
+	greeter
+}
+
+func (g *orderedGreeter) SayHelloDuplex(stream examples.Greeter_SayHelloDuplexServer) error {
+	return OrderedDuplex[examples.Request, examples.Response](stream, func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		if req.Name == "fail" {
+			return nil, status.Error(codes.InvalidArgument, "bad request")
+		}
+
+		n, _ := strconv.Atoi(req.Name)
+		time.Sleep(time.Duration(n) * 10 * time.Millisecond)
+
+		return &examples.Response{Message: "Hello, " + req.Name}, nil
+	}, 4)
+}
+
+func TestOrderedDuplex(t *testing.T) {
+	client, _ := startGreeter(t, &orderedGreeter{}, nil)
+	stream, err := client.SayHelloDuplex(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Earlier requests take longer, so the handlers finish in reverse.
+	names := []string{"8", "6", "4", "2", "0"}
+
+	for _, name := range names {
+		if err := stream.Send(&examples.Request{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream.CloseSend()
+
+	for _, name := range names {
+		res, err := stream.Recv()
+
+		if err != nil {
+			t.Fatal(err)
+		} else if want := "Hello, " + name; res.Message != want {
+			t.Errorf("response = %q, want %q", res.Message, want)
+		}
+	}
+
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
+
+func TestOrderedDuplexHandlerError(t *testing.T) {
+	client, _ := startGreeter(t, &orderedGreeter{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.SayHelloDuplex(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err := stream.Send(&examples.Request{Name: "fail"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The send side stays open, the handler error alone must end the stream.
+	start := time.Now()
+
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument", err)
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stream ended after %v, long after the failure", elapsed)
+	}
+}
+
+// ackingGreeter echoes duplex requests like greeter, which acknowledges them
+// as the sequence number leads the name. The first stream drops after
+// acknowledging dropAfter requests.
+type ackingGreeter struct {
+	greeter
+	dropAfter int
+	streams   atomic.Int32
+}
+
+func (g *ackingGreeter) SayHelloDuplex(stream examples.Greeter_SayHelloDuplexServer) error {
+	if g.streams.Add(1) > 1 {
+		return g.greeter.SayHelloDuplex(stream)
+	}
+
+	for i := 0; ; i++ {
+		req, err := stream.Recv()
+
+		if err != nil {
+			return err
+		} else if i == g.dropAfter {
+			return status.Error(codes.Unavailable, "connection lost")
+		} else if err := stream.Send(&examples.Response{Message: "Hello, " + req.Name}); err != nil {
+			return err
+		}
+	}
+}
+
+func newGreeterAckingDuplex() *AckingDuplex[examples.Request, examples.Response] {
+	return NewAckingDuplex(
+		func(req *examples.Request, seq uint64) {
+			req.Name = strconv.FormatUint(seq, 10) + ":" + req.Name
+		},
+		func(res *examples.Response) (uint64, bool) {
+			seq, _, ok := strings.Cut(strings.TrimPrefix(res.Message, "Hello, "), ":")
+			n, err := strconv.ParseUint(seq, 10, 64)
+
+			return n, ok && err == nil
+		},
+	)
+}
+
+func TestAckingDuplexResend(t *testing.T) {
+	client, _ := startGreeter(t, &ackingGreeter{dropAfter: 1}, nil)
+	stream, err := client.SayHelloDuplex(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newGreeterAckingDuplex()
+
+	if err := d.Attach(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the server has dropped the stream, sends may fail with io.EOF, the
+	// requests are recorded for the resend all the same.
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		if err := d.Send(&examples.Request{Name: name}); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+	}
+
+	if res, err := d.Recv(); err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, 1:Alice" {
+		t.Errorf("response = %q", res.Message)
+	}
+
+	if _, err := d.Recv(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("err = %v, want the disconnect", err)
+	}
+
+	if got := len(d.Unacked()); got != 2 {
+		t.Fatalf("%d unacknowledged requests, want 2", got)
+	}
+
+	stream, err = client.SayHelloDuplex(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err := d.Attach(stream); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"Hello, 2:Bob", "Hello, 3:Carol"} {
+		if res, err := d.Recv(); err != nil {
+			t.Fatal(err)
+		} else if res.Message != want {
+			t.Errorf("response = %q, want %q", res.Message, want)
+		}
+	}
+
+	if got := d.Unacked(); len(got) != 0 {
+		t.Errorf("unacknowledged after the resend: %v", got)
+	}
+}
+
+func TestAckingDuplexRecvDuringSend(t *testing.T) {
+	client, _ := startGreeter(t, &greeter{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.SayHelloDuplex(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newGreeterAckingDuplex()
+	d.Attach(stream)
+
+	// Large requests fill the flow control window, so sends block until the
+	// responses are received, which must not wait for the sends.
+	name := strings.Repeat("x", 64<<10)
+	done := make(chan error, 1)
+
+	go func() {
+		for i := 0; i < 50; i++ {
+			if err := d.Send(&examples.Request{Name: name}); err != nil {
+				done <- err
+				return
+			}
+		}
+
+		done <- stream.CloseSend()
+	}()
+
+	for i := 0; i < 50; i++ {
+		if _, err := d.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	} else if got := len(d.Unacked()); got != 0 {
+		t.Errorf("%d unacknowledged requests", got)
+	}
+}
+
+// muxGreeter serves logical channels over SayHelloDuplex: a request named
+// "id:name" is answered with "id:Hello, name", and the end marker "id!" of a
+// channel with an end marker of its own.
+type muxGreeter struct {
+	greeter
+}
+
+func (g *muxGreeter) SayHelloDuplex(stream examples.Greeter_SayHelloDuplexServer) error {
+	for {
+		req, err := stream.Recv()
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		res := &examples.Response{Message: req.Name}
+
+		if id, name, ok := strings.Cut(req.Name, ":"); ok {
+			res.Message = id + ":Hello, " + name
+		}
+
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+}
+
+func newGreeterMultiplex(t *testing.T, opts ...StreamOption) *MultiplexDuplex[examples.Request, examples.Response] {
+	t.Helper()
+	client, _ := startGreeter(t, &muxGreeter{}, nil)
+
+	return greeterMultiplex(t, client, opts...)
+}
+
+// greeterMultiplex opens a MultiplexDuplex over SayHelloDuplex of client,
+// which must serve it with a muxGreeter.
+func greeterMultiplex(
+	t *testing.T,
+	client examples.GreeterClient,
+	opts ...StreamOption,
+) *MultiplexDuplex[examples.Request, examples.Response] {
+	t.Helper()
+	stream, err := client.SayHelloDuplex(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewMultiplexDuplex(stream,
+		func(req *examples.Request, id string, end bool) {
+			if end {
+				req.Name = id + "!"
+			} else {
+				req.Name = id + ":" + req.Name
+			}
+		},
+		func(res *examples.Response) (string, bool) {
+			if id, ok := strings.CutSuffix(res.Message, "!"); ok {
+				return id, true
+			}
+
+			id, _, _ := strings.Cut(res.Message, ":")
+			return id, false
+		},
+		opts...,
+	)
+}
+
+func TestMultiplexDuplex(t *testing.T) {
+	client, conn := startGreeter(t, &muxGreeter{}, nil)
+
+	assertNoLeak(t, conn, func() {
+		m := greeterMultiplex(t, client)
+		sendA, recvA := m.OpenChannel("a")
+		sendB, recvB := m.OpenChannel("b")
+
+		if again, _ := m.OpenChannel("a"); again != nil {
+			t.Fatal("channel a opened twice")
+		}
+
+		sendA <- &examples.Request{Name: "Alice"}
+		sendB <- &examples.Request{Name: "Bob"}
+
+		if res := <-recvA; res.Message != "a:Hello, Alice" {
+			t.Errorf("channel a received %q", res.Message)
+		}
+
+		if res := <-recvB; res.Message != "b:Hello, Bob" {
+			t.Errorf("channel b received %q", res.Message)
+		}
+
+		// Closing a ends it on both sides, while b carries on.
+		close(sendA)
+
+		if _, ok := <-recvA; ok {
+			t.Error("channel a still open after its end")
+		}
+
+		sendA, recvA = m.OpenChannel("a")
+
+		if sendA == nil {
+			t.Fatal("channel a cannot be opened again")
+		}
+
+		sendA <- &examples.Request{Name: "Carol"}
+		sendB <- &examples.Request{Name: "Dave"}
+
+		if res := <-recvA; res.Message != "a:Hello, Carol" {
+			t.Errorf("reopened channel a received %q", res.Message)
+		}
+
+		if res := <-recvB; res.Message != "b:Hello, Dave" {
+			t.Errorf("channel b received %q", res.Message)
+		}
+
+		m.CloseSend()
+		close(sendA)
+		close(sendB)
+
+		for range recvA {
+		}
+
+		for range recvB {
+		}
+
+		if err := m.Err(); err != nil {
+			t.Errorf("stream ended with %v", err)
+		}
+
+		if send, _ := m.OpenChannel("c"); send != nil {
+			t.Error("channel opened after CloseSend")
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorClassifier maps status codes to caller-defined sentinel errors, so
+// that callers can match domain errors instead of inspecting status codes:
+//
+//	classify := grpcasync.ErrorClassifier{codes.NotFound: ErrUserNotFound}
+//	_, err := client.GetUser(ctx, req)
+//
+//	if errors.Is(classify.Classify(err), ErrUserNotFound) {
+//		// ...
+//	}
+type ErrorClassifier map[codes.Code]error
+
+// Classify wraps err with the sentinel registered for its status code. Both
+// the sentinel and the original error remain reachable through errors.Is and
+// errors.As. Errors with unmapped codes, and nil, are returned as is.
+func (c ErrorClassifier) Classify(err error) error {
+	if err == nil {
+		return nil
+	} else if sentinel, ok := c[status.Code(err)]; ok {
+		return fmt.Errorf("%w: %w", sentinel, err)
+	}
+
+	return err
+}
+
+// NormalizeContextErrors returns a client interceptor that converts bare
+// context.Canceled and context.DeadlineExceeded errors, which some code paths
+// return instead of a status, into Canceled and DeadlineExceeded statuses, so
+// that callers can rely on status codes alone.
+func NormalizeContextErrors() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		return normalizeContextError(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// NormalizeStreamContextErrors is the streaming variant of
+// NormalizeContextErrors, it applies to errors from opening a stream.
+func NormalizeStreamContextErrors() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		return stream, normalizeContextError(err)
+	}
+}
+
+func normalizeContextError(err error) error {
+	if err == nil {
+		return nil
+	} else if _, ok := status.FromError(err); ok {
+		return err
+	} else if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	return err
+}
+
+// connectionErrorHints are fragments of the messages gRPC-Go uses for
+// Unavailable statuses caused by the connection rather than the server.
+var connectionErrorHints = []string{
+	"connection error",
+	"connection refused",
+	"connection reset",
+	"connection closed",
+	"transport is closing",
+	"error reading from server",
+	"failed to write",
+	"error while dialing",
+	"no such host",
+	"i/o timeout",
+}
+
+// IsConnectionError reports whether err was caused by failing to connect to
+// the server or by losing the connection, as opposed to an error returned by
+// the application, even an Unavailable one. Such errors usually call for
+// reconnecting or trying another backend.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var opErr *net.OpError
+
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	st, ok := status.FromError(err)
+
+	if !ok || st.Code() != codes.Unavailable {
+		return false
+	}
+
+	msg := strings.ToLower(st.Message())
+
+	for _, hint := range connectionErrorHints {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+func TestErrorClassifier(t *testing.T) {
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		switch req.Name {
+		case "":
+			return nil, status.Error(codes.InvalidArgument, "empty name")
+		case "World":
+			return &examples.Response{Message: "Hello, World"}, nil
+		default:
+			return nil, status.Errorf(codes.NotFound, "no user %s", req.Name)
+		}
+	}}, nil)
+
+	classify := ErrorClassifier{codes.NotFound: errUserNotFound}
+
+	tests := []struct {
+		name     string
+		sentinel bool
+		wantCode codes.Code
+	}{
+		{"Alice", true, codes.NotFound},
+		{"", false, codes.InvalidArgument},
+		{"World", false, codes.OK},
+	}
+
+	for _, tt := range tests {
+		_, err := client.SayHello(context.Background(), &examples.Request{Name: tt.name})
+		err = classify.Classify(err)
+
+		if got := errors.Is(err, errUserNotFound); got != tt.sentinel {
+			t.Errorf("%q: errors.Is(err, errUserNotFound) = %v, want %v", tt.name, got, tt.sentinel)
+		}
+
+		// The status of the original error must remain reachable.
+		var st interface{ GRPCStatus() *status.Status }
+
+		if tt.wantCode == codes.OK {
+			if err != nil {
+				t.Errorf("%q: err = %v", tt.name, err)
+			}
+		} else if !errors.As(err, &st) || st.GRPCStatus().Code() != tt.wantCode {
+			t.Errorf("%q: err = %v, want a %v status", tt.name, err, tt.wantCode)
+		}
+	}
+}
+
+func TestNormalizeContextErrors(t *testing.T) {
+	// bare stands in for the code paths that fail with the context error
+	// itself rather than a status. The server may give up on the deadline
+	// just before the client does, leaving the status as is.
+	bare := func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return err
+	}
+
+	client, _ := startGreeter(t, &sleepyGreeter{}, nil,
+		grpc.WithChainUnaryInterceptor(NormalizeContextErrors(), bare))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	timedOut, cancelTimeout := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelTimeout()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"canceled", canceled, codes.Canceled},
+		{"deadline", timedOut, codes.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		_, err := client.SayHello(tt.ctx, &examples.Request{Name: "1s"})
+
+		if _, ok := status.FromError(err); !ok {
+			t.Errorf("%s: err = %v is not a status", tt.name, err)
+		} else if code := status.Code(err); code != tt.want {
+			t.Errorf("%s: code = %v, want %v", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	conn, err := grpc.Dial("passthrough:///unreachable",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return nil, errors.New("no route to backend")
+		}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	_, dialErr := examples.NewGreeterClient(conn).SayHello(context.Background(), &examples.Request{})
+
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		if req.Name == "dialog" {
+			return nil, status.Error(codes.Unavailable, "the dialog service is down")
+		}
+
+		return nil, status.Error(codes.NotFound, "no such user")
+	}}, nil)
+	_, notFound := client.SayHello(context.Background(), &examples.Request{Name: "World"})
+	_, unavailable := client.SayHello(context.Background(), &examples.Request{Name: "dialog"})
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial failure", dialErr, true},
+		{"not found", notFound, false},
+		{"application unavailable", unavailable, false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsConnectionError(tt.err); got != tt.want {
+			t.Errorf("%s: IsConnectionError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// FailoverConn is a grpc.ClientConnInterface sending all calls to the
+// primary endpoint until it fails, then to the secondaries in order. Only
+// errors reported by IsConnectionError count as failures. While failed over,
+// the primary is probed in the background and traffic returns to it as soon
+// as it is ready again.
+type FailoverConn struct {
+	conns         []*grpc.ClientConn
+	threshold     int
+	probeInterval time.Duration
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+
+	mu       sync.Mutex
+	active   int
+	failures int
+}
+
+// ConnectFailover dials primary and secondaries and returns a client of type
+// T built by factory over a FailoverConn preferring them in that order. The
+// FailoverConn moves to the next endpoint after threshold consecutive
+// connection errors, at least 1, and while failed over checks every
+// probeInterval whether the primary has recovered. probeInterval must be
+// positive.
+func ConnectFailover[T any](
+	primary string,
+	secondaries []string,
+	threshold int,
+	probeInterval time.Duration,
+	factory func(grpc.ClientConnInterface) T,
+	opts ...grpc.DialOption,
+) (T, *FailoverConn, error) {
+	if probeInterval <= 0 {
+		var zero T
+		return zero, nil, fmt.Errorf("grpcasync: probe interval must be positive, got %v", probeInterval)
+	}
+
+	conn := &FailoverConn{
+		threshold:     max(threshold, 1),
+		probeInterval: probeInterval,
+		done:          make(chan struct{}),
+	}
+
+	for _, addr := range append([]string{primary}, secondaries...) {
+		target, err := NormalizeTarget(addr)
+
+		if err == nil {
+			var cc *grpc.ClientConn
+			cc, err = grpc.Dial(target, opts...)
+			conn.conns = append(conn.conns, cc)
+		}
+
+		if err != nil {
+			conn.Close()
+			var zero T
+			return zero, nil, err
+		}
+	}
+
+	conn.wg.Add(1)
+	go conn.probe()
+
+	return factory(conn), conn, nil
+}
+
+// Active returns the index of the endpoint calls are sent to, 0 for the
+// primary and i+1 for the i-th secondary.
+func (c *FailoverConn) Active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.active
+}
+
+// Close stops probing and closes the connections to all endpoints. Further
+// calls return the result of the first one.
+func (c *FailoverConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.wg.Wait()
+
+		var errs []error
+
+		for _, cc := range c.conns {
+			if cc != nil {
+				errs = append(errs, cc.Close())
+			}
+		}
+
+		c.closeErr = errors.Join(errs...)
+	})
+
+	return c.closeErr
+}
+
+// Invoke implements grpc.ClientConnInterface.
+func (c *FailoverConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	i := c.Active()
+	err := c.conns[i].Invoke(ctx, method, args, reply, opts...)
+	c.report(i, err)
+
+	return err
+}
+
+// NewStream implements grpc.ClientConnInterface. Only failures to open the
+// stream count towards failing over.
+func (c *FailoverConn) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	i := c.Active()
+	stream, err := c.conns[i].NewStream(ctx, desc, method, opts...)
+	c.report(i, err)
+
+	return stream, err
+}
+
+// report records the outcome of a call sent to the endpoint i, ignoring
+// calls that were sent before the last switch.
+func (c *FailoverConn) report(i int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i != c.active {
+		return
+	} else if !IsConnectionError(err) {
+		c.failures = 0
+		return
+	}
+
+	c.failures++
+
+	if c.failures >= c.threshold {
+		c.active = (c.active + 1) % len(c.conns)
+		c.failures = 0
+	}
+}
+
+func (c *FailoverConn) probe() {
+	defer c.wg.Done()
+	ticker := time.NewTicker(c.probeInterval)
+	defer ticker.Stop()
+
+	primary := c.conns[0]
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		if c.Active() == 0 {
+			continue
+		}
+
+		switch primary.GetState() {
+		case connectivity.Ready:
+			c.mu.Lock()
+			c.active, c.failures = 0, 0
+			c.mu.Unlock()
+		case connectivity.Idle:
+			primary.Connect()
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package grpcasync
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// failoverBackends serves a namedGreeter per name and lets the tests kill and
+// restart them.
+type failoverBackends struct {
+	t testing.TB
+
+	mu        sync.Mutex
+	servers   map[string]*grpc.Server
+	listeners map[string]*bufconn.Listener
+}
+
+func (b *failoverBackends) start(name string) {
+	srv := grpc.NewServer()
+	examples.RegisterGreeterServer(srv, &namedGreeter{name: name})
+	lis := serve(b.t, srv)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.servers[name], b.listeners[name] = srv, lis
+}
+
+func (b *failoverBackends) kill(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.servers[name].Stop()
+}
+
+func (b *failoverBackends) dialer() grpc.DialOption {
+	return grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		b.mu.Lock()
+		lis := b.listeners[addr]
+		b.mu.Unlock()
+
+		return lis.DialContext(ctx)
+	})
+}
+
+func TestConnectFailover(t *testing.T) {
+	backends := &failoverBackends{t: t, servers: map[string]*grpc.Server{}, listeners: map[string]*bufconn.Listener{}}
+	backends.start("primary")
+	backends.start("secondary")
+
+	client, conn, err := ConnectFailover(
+		"passthrough:///primary",
+		[]string{"passthrough:///secondary"},
+		2,
+		20*time.Millisecond,
+		examples.NewGreeterClient,
+		backends.dialer(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.Config{BaseDelay: 10 * time.Millisecond, Multiplier: 1, MaxDelay: 10 * time.Millisecond},
+			MinConnectTimeout: time.Second,
+		}),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// backendOf calls until one succeeds, as the calls caught by a failure
+	// of the primary fail.
+	backendOf := func() string {
+		for {
+			if res, err := client.SayHello(ctx, &examples.Request{}); err == nil {
+				return res.Message
+			} else if ctx.Err() != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if backend := backendOf(); backend != "primary" {
+			t.Fatalf("call %d went to %s while the primary is up", i, backend)
+		}
+	}
+
+	backends.kill("primary")
+
+	if backend := backendOf(); backend != "secondary" {
+		t.Errorf("call went to %s after killing the primary", backend)
+	} else if active := conn.Active(); active != 1 {
+		t.Errorf("Active = %d, want the secondary", active)
+	}
+
+	// Once the primary is back, the probe sends traffic to it again.
+	backends.start("primary")
+
+	for conn.Active() != 0 {
+		if ctx.Err() != nil {
+			t.Fatal("traffic never returned to the recovered primary")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if backend := backendOf(); backend != "primary" {
+		t.Errorf("call went to %s after the primary recovered", backend)
+	}
+}
+
+func TestFailoverConnCloseTwice(t *testing.T) {
+	_, conn, err := ConnectFailover(
+		"passthrough:///primary",
+		nil,
+		1,
+		time.Hour,
+		examples.NewGreeterClient,
+		serveBackends(t, "primary"),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := conn.Close()
+
+	if err := conn.Close(); err != first {
+		t.Errorf("second Close = %v, want %v", err, first)
+	}
+}
+
+func TestConnectFailoverProbeInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		_, conn, err := ConnectFailover(
+			"passthrough:///primary",
+			nil,
+			1,
+			interval,
+			examples.NewGreeterClient,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+
+		if err == nil {
+			conn.Close()
+			t.Errorf("no error for a probe interval of %v", interval)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamingFallback describes the server-streaming method that serves the
+// same data as a unary method in parts small enough to fit the message size
+// limit.
+type StreamingFallback struct {
+	// Method is the full name of the server-streaming method, it takes the
+	// same request as the unary method.
+	Method string
+	// NewPart returns an empty message to receive a part into.
+	NewPart func() any
+	// Assemble merges all received parts into reply, which is the response
+	// message of the unary call.
+	Assemble func(parts []any, reply any) error
+}
+
+// StreamingFallbackInterceptor returns a client interceptor that retries a
+// unary call against its streaming counterpart when the response is rejected
+// with ResourceExhausted for exceeding the maximum message size. Only the
+// methods listed in fallbacks, keyed by full method name, are affected.
+func StreamingFallbackInterceptor(fallbacks map[string]StreamingFallback) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		fallback, ok := fallbacks[method]
+
+		if !ok || !isTooLarge(err) {
+			return err
+		}
+
+		desc := &grpc.StreamDesc{ServerStreams: true}
+		stream, err := cc.NewStream(ctx, desc, fallback.Method, opts...)
+
+		if err != nil {
+			return err
+		} else if err := stream.SendMsg(req); err != nil && err != io.EOF {
+			return err
+		} else if err := stream.CloseSend(); err != nil {
+			return err
+		}
+
+		var parts []any
+
+		for {
+			part := fallback.NewPart()
+
+			if err := stream.RecvMsg(part); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+
+			parts = append(parts, part)
+		}
+
+		return fallback.Assemble(parts, reply)
+	}
+}
+
+func isTooLarge(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.ResourceExhausted &&
+		strings.Contains(st.Message(), "larger than max")
+}
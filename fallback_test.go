@@ -0,0 +1,88 @@
+package grpcasync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+)
+
+// largeGreeter answers SayHello with a message of size bytes, which
+// SayHelloStreamReply splits into parts of 256 bytes.
+type largeGreeter struct {
+	greeter
+	size int
+}
+
+func (g *largeGreeter) message() string {
+	return strings.Repeat("x", g.size)
+}
+
+func (g *largeGreeter) SayHello(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	return &examples.Response{Message: g.message()}, nil
+}
+
+func (g *largeGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	msg := g.message()
+
+	for len(msg) > 0 {
+		n := min(len(msg), 256)
+
+		if err := stream.Send(&examples.Response{Message: msg[:n]}); err != nil {
+			return err
+		}
+
+		msg = msg[n:]
+	}
+
+	return nil
+}
+
+func TestStreamingFallbackInterceptor(t *testing.T) {
+	fallbacks := map[string]StreamingFallback{
+		sayHelloMethod: {
+			Method:  streamReplyMethod,
+			NewPart: func() any { return new(examples.Response) },
+			Assemble: func(parts []any, reply any) error {
+				var b strings.Builder
+
+				for _, part := range parts {
+					b.WriteString(part.(*examples.Response).Message)
+				}
+
+				reply.(*examples.Response).Message = b.String()
+				return nil
+			},
+		},
+	}
+
+	impl := &largeGreeter{size: 4096}
+	client, _ := startGreeter(t, impl, nil,
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024)),
+		grpc.WithUnaryInterceptor(StreamingFallbackInterceptor(fallbacks)),
+	)
+
+	res, err := client.SayHello(context.Background(), &examples.Request{Name: "World"})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Message != impl.message() {
+		t.Errorf("reassembled %d bytes, want %d", len(res.Message), impl.size)
+	}
+}
+
+func TestStreamingFallbackInterceptorSmall(t *testing.T) {
+	client, _ := startGreeter(t, &largeGreeter{size: 16}, nil,
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024)),
+		grpc.WithUnaryInterceptor(StreamingFallbackInterceptor(map[string]StreamingFallback{
+			sayHelloMethod: {Method: "/examples.Greeter/Unknown"},
+		})),
+	)
+
+	// The fallback would fail, so it must not be used for responses that fit.
+	if _, err := client.SayHello(context.Background(), &examples.Request{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package grpcasync
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Fault describes the faults injected into calls of a method.
+type Fault struct {
+	// Delay is added before the call with probability DelayProbability.
+	Delay            time.Duration
+	DelayProbability float64
+	// Code is returned instead of performing the call with probability
+	// ErrorProbability.
+	Code             codes.Code
+	ErrorProbability float64
+}
+
+// FaultConfig configures fault injection for resilience testing.
+type FaultConfig struct {
+	// Methods maps full method names to the faults injected into them.
+	Methods map[string]Fault
+	// Rand returns numbers in [0, 1) to decide whether a fault occurs, it
+	// defaults to math/rand.Float64. Supply a seeded source for
+	// deterministic tests; it must be safe for concurrent use.
+	Rand func() float64
+}
+
+// FaultInjectionInterceptor returns a server interceptor that delays calls or
+// fails them with a configured status code, at the configured probabilities.
+func FaultInjectionInterceptor(cfg FaultConfig) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := cfg.inject(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// FaultInjectionClientInterceptor is the client-side counterpart of
+// FaultInjectionInterceptor, faults are injected before the call is sent.
+func FaultInjectionClientInterceptor(cfg FaultConfig) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if err := cfg.inject(ctx, method); err != nil {
+			return err
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (cfg FaultConfig) inject(ctx context.Context, method string) error {
+	fault, ok := cfg.Methods[method]
+
+	if !ok {
+		return nil
+	}
+
+	random := cfg.Rand
+
+	if random == nil {
+		random = rand.Float64
+	}
+
+	if fault.Delay > 0 && random() < fault.DelayProbability {
+		timer := time.NewTimer(fault.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	if random() < fault.ErrorProbability {
+		return status.Errorf(fault.Code, "grpcasync: injected fault in %s", method)
+	}
+
+	return nil
+}
+
+// LatencyInjectionInterceptor returns a server interceptor adding the
+// configured delay before every call of each method, e.g. for validating the
+// timeouts and retries of clients in load tests. It is a deterministic form
+// of FaultInjectionInterceptor, calls canceled while waiting fail with their
+// context error.
+func LatencyInjectionInterceptor(per map[string]time.Duration) grpc.UnaryServerInterceptor {
+	cfg := FaultConfig{Methods: make(map[string]Fault, len(per))}
+
+	for method, delay := range per {
+		cfg.Methods[method] = Fault{Delay: delay, DelayProbability: 1}
+	}
+
+	return FaultInjectionInterceptor(cfg)
+}
@@ -0,0 +1,124 @@
+package grpcasync
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// countingGreeter counts the SayHello calls reaching it.
+type countingGreeter struct {
+	greeter
+	calls atomic.Int32
+}
+
+func (g *countingGreeter) SayHello(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	g.calls.Add(1)
+	return g.greeter.SayHello(ctx, req)
+}
+
+func TestFaultInjectionInterceptor(t *testing.T) {
+	cfg := FaultConfig{Methods: map[string]Fault{
+		sayHelloMethod: {Code: codes.Unavailable, ErrorProbability: 1},
+	}}
+
+	tests := []struct {
+		name  string
+		sopts []grpc.ServerOption
+		copts []grpc.DialOption
+	}{
+		{"server", []grpc.ServerOption{grpc.UnaryInterceptor(FaultInjectionInterceptor(cfg))}, nil},
+		{"client", nil, []grpc.DialOption{grpc.WithUnaryInterceptor(FaultInjectionClientInterceptor(cfg))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impl := &countingGreeter{}
+			client, _ := startGreeter(t, impl, tt.sopts, tt.copts...)
+
+			for i := 0; i < 10; i++ {
+				if _, err := client.SayHello(context.Background(), &examples.Request{}); status.Code(err) != codes.Unavailable {
+					t.Fatalf("err = %v, want Unavailable", err)
+				}
+			}
+
+			if got := impl.calls.Load(); got != 0 {
+				t.Errorf("%d calls reached the handler", got)
+			}
+		})
+	}
+}
+
+func TestFaultInjectionInterceptorRand(t *testing.T) {
+	// The source alternates between firing and not firing the fault.
+	var n atomic.Int32
+	cfg := FaultConfig{
+		Methods: map[string]Fault{
+			sayHelloMethod: {Code: codes.Unavailable, ErrorProbability: 0.5},
+		},
+		Rand: func() float64 {
+			if n.Add(1)%2 == 1 {
+				return 0.25
+			}
+
+			return 0.75
+		},
+	}
+
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(FaultInjectionInterceptor(cfg)),
+	})
+
+	for i, want := range []codes.Code{codes.Unavailable, codes.OK, codes.Unavailable, codes.OK} {
+		if _, err := client.SayHello(context.Background(), &examples.Request{}); status.Code(err) != want {
+			t.Errorf("call %d: err = %v, want %v", i, err, want)
+		}
+	}
+}
+
+func TestFaultInjectionDelay(t *testing.T) {
+	cfg := FaultConfig{Methods: map[string]Fault{
+		sayHelloMethod: {Delay: 50 * time.Millisecond, DelayProbability: 1},
+	}}
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(FaultInjectionInterceptor(cfg)),
+	})
+
+	start := time.Now()
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{}); err != nil {
+		t.Fatal(err)
+	} else if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("call took %v, want the injected 50ms at least", elapsed)
+	}
+}
+
+func TestLatencyInjectionInterceptor(t *testing.T) {
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(LatencyInjectionInterceptor(map[string]time.Duration{
+			sayHelloMethod: 50 * time.Millisecond,
+		})),
+	})
+
+	start := time.Now()
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{}); err != nil {
+		t.Fatal(err)
+	} else if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("call took %v, want the injected 50ms at least", elapsed)
+	}
+
+	// Calls giving up while delayed fail with their context error.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.SayHello(ctx, &examples.Request{}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+}
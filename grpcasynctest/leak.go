@@ -0,0 +1,71 @@
+package grpcasynctest
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AssertNoLeak runs fn and fails the test if any goroutine started while it
+// ran is still running afterwards, once they have had settle to finish, e.g.
+// a second. Goroutines are told apart by their IDs, so those that were
+// already running before fn, such as the ones of earlier tests winding down,
+// neither count as leaks nor hide them. The stacks of the leaked goroutines
+// are logged on failure to help locate the leak.
+//
+// The check sees all goroutines of the process, so it must not be used in
+// tests running in parallel.
+func AssertNoLeak(t testing.TB, settle time.Duration, fn func()) {
+	t.Helper()
+	before := goroutines()
+	fn()
+
+	deadline := time.Now().Add(settle)
+	leaked := started(before)
+
+	for len(leaked) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		leaked = started(before)
+	}
+
+	if len(leaked) > 0 {
+		t.Fatalf("%d goroutine(s) leaked:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+	}
+}
+
+// goroutines returns the stacks of all goroutines by their IDs.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+
+	for {
+		if n := runtime.Stack(buf, true); n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := map[string]string{}
+
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		id, _, _ := strings.Cut(strings.TrimPrefix(stack, "goroutine "), " ")
+		stacks[id] = stack
+	}
+
+	return stacks
+}
+
+// started returns the stacks of the goroutines not in before.
+func started(before map[string]string) []string {
+	var stacks []string
+
+	for id, stack := range goroutines() {
+		if _, ok := before[id]; !ok {
+			stacks = append(stacks, stack)
+		}
+	}
+
+	return stacks
+}
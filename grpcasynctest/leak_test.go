@@ -0,0 +1,77 @@
+package grpcasynctest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type greeter struct {
+	examples.UnimplementedGreeterServer
+}
+
+func (g *greeter) SayHello(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	return &examples.Response{Message: "Hello, " + req.Name}, nil
+}
+
+func TestAssertNoLeak(t *testing.T) {
+	// A full call over a connection that is closed again leaves nothing
+	// behind.
+	r := &recorder{TB: t}
+	AssertNoLeak(r, time.Second, func() {
+		lis := bufconn.Listen(1 << 20)
+		srv := grpc.NewServer()
+		examples.RegisterGreeterServer(srv, &greeter{})
+		go srv.Serve(lis)
+		defer srv.Stop()
+
+		conn, err := grpc.Dial("passthrough:///bufconn",
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer conn.Close()
+
+		if _, err := examples.NewGreeterClient(conn).SayHello(context.Background(), &examples.Request{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if r.failure != "" {
+		t.Errorf("leak reported for a clean call:\n%s", r.failure)
+	}
+}
+
+func TestAssertNoLeakLeaked(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	r := &recorder{TB: t}
+	start := time.Now()
+	// A goroutine of an earlier test winds down while fn runs, which must
+	// not hide the one fn leaks.
+	winding := make(chan struct{})
+	go func() { <-winding }()
+
+	AssertNoLeak(r, 50*time.Millisecond, func() {
+		close(winding)
+		go func() { <-release }()
+	})
+
+	if r.failure == "" {
+		t.Error("no leak reported for a blocked goroutine")
+	} else if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("leak reported after %v, before the settle time", elapsed)
+	}
+}
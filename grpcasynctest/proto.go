@@ -0,0 +1,178 @@
+package grpcasynctest
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProtoEqual reports whether a and b are equal proto messages, ignoring the
+// internal state that makes reflect.DeepEqual unreliable for them.
+func ProtoEqual(a, b proto.Message) bool {
+	return proto.Equal(a, b)
+}
+
+// ProtoDiff returns a readable, field-by-field description of how a and b
+// differ, one field per line, or an empty string if they are equal. Like
+// proto.Equal, it accepts nil and invalid messages, i.e. typed nil pointers,
+// and compares unknown fields too.
+func ProtoDiff(a, b proto.Message) string {
+	if proto.Equal(a, b) {
+		return ""
+	} else if a == nil || b == nil {
+		return fmt.Sprintf("%s != %s\n", describeMessage(a), describeMessage(b))
+	}
+
+	ma, mb := a.ProtoReflect(), b.ProtoReflect()
+
+	if ma.Descriptor().FullName() != mb.Descriptor().FullName() {
+		return fmt.Sprintf("type: %s != %s\n", ma.Descriptor().FullName(), mb.Descriptor().FullName())
+	} else if !ma.IsValid() || !mb.IsValid() {
+		return fmt.Sprintf("%s != %s\n", describeMessage(a), describeMessage(b))
+	}
+
+	var lines []string
+	diffMessage("", ma, mb, &lines)
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func diffMessage(path string, a, b protoreflect.Message, lines *[]string) {
+	fields := a.Descriptor().Fields()
+
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := path + string(fd.Name())
+		hasA, hasB := a.Has(fd), b.Has(fd)
+
+		switch {
+		case !hasA && !hasB:
+			continue
+		case fd.IsList():
+			diffList(name, fd, a.Get(fd).List(), b.Get(fd).List(), lines)
+		case fd.IsMap():
+			diffMap(name, fd, a.Get(fd).Map(), b.Get(fd).Map(), lines)
+		case fd.Message() != nil && hasA && hasB:
+			diffMessage(name+".", a.Get(fd).Message(), b.Get(fd).Message(), lines)
+		default:
+			diffValue(name, fd, a.Get(fd), b.Get(fd), hasA, hasB, lines)
+		}
+	}
+
+	if ua, ub := a.GetUnknown(), b.GetUnknown(); !bytes.Equal(ua, ub) {
+		*lines = append(*lines, fmt.Sprintf("%s<unknown fields>: %s != %s", path, formatUnknown(ua), formatUnknown(ub)))
+	}
+}
+
+// describeMessage names m for messages that cannot be compared field by
+// field.
+func describeMessage(m proto.Message) string {
+	if m == nil {
+		return "<nil>"
+	} else if !m.ProtoReflect().IsValid() {
+		return fmt.Sprintf("<nil %s>", m.ProtoReflect().Descriptor().FullName())
+	}
+
+	return fmt.Sprintf("%s{%s}", m.ProtoReflect().Descriptor().FullName(), prototext.MarshalOptions{}.Format(m))
+}
+
+func formatUnknown(raw protoreflect.RawFields) string {
+	if len(raw) == 0 {
+		return "<unset>"
+	}
+
+	return fmt.Sprintf("%q", []byte(raw))
+}
+
+func diffList(path string, fd protoreflect.FieldDescriptor, a, b protoreflect.List, lines *[]string) {
+	for i := 0; i < a.Len() || i < b.Len(); i++ {
+		name := fmt.Sprintf("%s[%d]", path, i)
+		hasA, hasB := i < a.Len(), i < b.Len()
+		var va, vb protoreflect.Value
+
+		if hasA {
+			va = a.Get(i)
+		}
+
+		if hasB {
+			vb = b.Get(i)
+		}
+
+		if fd.Message() != nil && hasA && hasB {
+			diffMessage(name+".", va.Message(), vb.Message(), lines)
+		} else {
+			diffValue(name, fd, va, vb, hasA, hasB, lines)
+		}
+	}
+}
+
+func diffMap(path string, fd protoreflect.FieldDescriptor, a, b protoreflect.Map, lines *[]string) {
+	keys := map[string]protoreflect.MapKey{}
+	collect := func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys[k.String()] = k
+		return true
+	}
+
+	a.Range(collect)
+	b.Range(collect)
+	names := make([]string, 0, len(keys))
+
+	for name := range keys {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	vd := fd.MapValue()
+
+	for _, key := range names {
+		k := keys[key]
+		name := fmt.Sprintf("%s[%s]", path, key)
+		hasA, hasB := a.Has(k), b.Has(k)
+
+		if vd.Message() != nil && hasA && hasB {
+			diffMessage(name+".", a.Get(k).Message(), b.Get(k).Message(), lines)
+		} else {
+			diffValue(name, vd, a.Get(k), b.Get(k), hasA, hasB, lines)
+		}
+	}
+}
+
+func diffValue(
+	path string,
+	fd protoreflect.FieldDescriptor,
+	a, b protoreflect.Value,
+	hasA, hasB bool,
+	lines *[]string,
+) {
+	sa, sb := formatValue(fd, a, hasA), formatValue(fd, b, hasB)
+
+	if sa != sb {
+		*lines = append(*lines, fmt.Sprintf("%s: %s != %s", path, sa, sb))
+	}
+}
+
+func formatValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, ok bool) string {
+	if !ok {
+		return "<unset>"
+	} else if fd.Message() != nil {
+		return "{" + prototext.MarshalOptions{}.Format(v.Message().Interface()) + "}"
+	}
+
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return fmt.Sprintf("%q", v.String())
+	case protoreflect.BytesKind:
+		return fmt.Sprintf("%q", v.Bytes())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+	}
+
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,92 @@
+package grpcasynctest
+
+import (
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestProtoDiff(t *testing.T) {
+	list := func(values ...any) *structpb.ListValue {
+		l, err := structpb.NewList(values)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return l
+	}
+
+	unknown := &examples.Response{}
+	unknown.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 99, protowire.VarintType), 1))
+
+	tests := []struct {
+		name string
+		a, b proto.Message
+		want string
+	}{
+		{
+			"equal",
+			&examples.Response{Message: "Hello, World"},
+			&examples.Response{Message: "Hello, World"},
+			"",
+		},
+		{
+			"field",
+			&examples.Response{Message: "Hello, World"},
+			&examples.Response{Message: "Hello, Alice"},
+			`message: "Hello, World" != "Hello, Alice"` + "\n",
+		},
+		{
+			"unset",
+			&examples.Response{Message: "Hello, World"},
+			&examples.Response{},
+			`message: "Hello, World" != <unset>` + "\n",
+		},
+		{
+			"type",
+			&examples.Response{},
+			&examples.Request{},
+			"type: examples.Response != examples.Request\n",
+		},
+		{
+			"list",
+			list("a", 1),
+			list("b", 1),
+			`values[0].string_value: "a" != "b"` + "\n",
+		},
+		{
+			"nil",
+			nil,
+			&examples.Response{},
+			"<nil> != examples.Response{}\n",
+		},
+		{
+			"invalid",
+			(*examples.Response)(nil),
+			&examples.Response{},
+			"<nil examples.Response> != examples.Response{}\n",
+		},
+		{
+			"unknown fields",
+			unknown,
+			&examples.Response{},
+			`<unknown fields>: "\x98\x06\x01" != <unset>` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProtoDiff(tt.a, tt.b); got != tt.want {
+				t.Errorf("ProtoDiff() = %q, want %q", got, tt.want)
+			}
+
+			if got := ProtoEqual(tt.a, tt.b); got != (tt.want == "") {
+				t.Errorf("ProtoEqual() = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package grpcasynctest
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Reply is the scripted outcome of one call to a ScriptedServer: after Delay,
+// Response is sent if it is not nil and the call ends with Err.
+type Reply struct {
+	Response proto.Message
+	Err      error
+	Delay    time.Duration
+}
+
+// ScriptedServer is an in-memory server answering calls to any method with
+// replies scripted per method and call index, for black-box client tests that
+// would otherwise need a fake implementation of the service:
+//
+//	srv := grpcasynctest.NewScriptedServer(t)
+//	srv.Script("/examples.Greeter/SayHello",
+//		grpcasynctest.Reply{Response: &examples.Response{Message: "first"}},
+//		grpcasynctest.Reply{Err: status.Error(codes.Unavailable, "down")},
+//	)
+//	client := examples.NewGreeterClient(srv.Conn())
+//
+// Each call reads at most one request and sends at most one response, which
+// suits unary and server-streaming methods. Calls past the end of the script
+// fail with Unimplemented.
+type ScriptedServer struct {
+	lis  *bufconn.Listener
+	conn *grpc.ClientConn
+
+	mu      sync.Mutex
+	replies map[string][]Reply
+	calls   map[string]int
+}
+
+// NewScriptedServer starts a ScriptedServer, which is stopped when the test
+// ends.
+func NewScriptedServer(t testing.TB) *ScriptedServer {
+	t.Helper()
+	s := &ScriptedServer{
+		lis:     bufconn.Listen(1 << 20),
+		replies: map[string][]Reply{},
+		calls:   map[string]int{},
+	}
+
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(s.handle))
+	go srv.Serve(s.lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("passthrough:///scripted",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.lis.DialContext(ctx)
+		}),
+	)
+
+	if err != nil {
+		t.Fatalf("grpcasynctest: dial scripted server: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	s.conn = conn
+
+	return s
+}
+
+// Conn returns a connection to the server.
+func (s *ScriptedServer) Conn() *grpc.ClientConn {
+	return s.conn
+}
+
+// Script appends replies to the script of method, given in its full form
+// "/package.Service/Method".
+func (s *ScriptedServer) Script(method string, replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replies[method] = append(s.replies[method], replies...)
+}
+
+// Calls returns the number of calls method has received.
+func (s *ScriptedServer) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+func (s *ScriptedServer) handle(_ any, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+
+	s.mu.Lock()
+	i := s.calls[method]
+	s.calls[method]++
+	replies := s.replies[method]
+	s.mu.Unlock()
+
+	if i >= len(replies) {
+		return status.Errorf(codes.Unimplemented, "grpcasynctest: no scripted reply for call %d of %s", i, method)
+	}
+
+	reply := replies[i]
+
+	// The request is only read to let the client finish sending it, its
+	// fields are kept as unknown fields of the empty message.
+	if err := stream.RecvMsg(new(emptypb.Empty)); err != nil && err != io.EOF {
+		return err
+	}
+
+	if reply.Delay > 0 {
+		timer := time.NewTimer(reply.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+
+	if reply.Response != nil {
+		if err := stream.SendMsg(reply.Response); err != nil {
+			return err
+		}
+	}
+
+	return reply.Err
+}
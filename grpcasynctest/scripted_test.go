@@ -0,0 +1,64 @@
+package grpcasynctest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const sayHelloMethod = "/examples.Greeter/SayHello"
+
+func TestScriptedServer(t *testing.T) {
+	srv := NewScriptedServer(t)
+	srv.Script(sayHelloMethod,
+		Reply{Response: &examples.Response{Message: "first"}},
+		Reply{Response: &examples.Response{Message: "second"}, Delay: 50 * time.Millisecond},
+		Reply{Err: status.Error(codes.Unavailable, "down")},
+	)
+	client := examples.NewGreeterClient(srv.Conn())
+
+	for _, want := range []string{"first", "second"} {
+		start := time.Now()
+		res, err := client.SayHello(context.Background(), &examples.Request{Name: "World"})
+
+		if err != nil {
+			t.Fatal(err)
+		} else if res.Message != want {
+			t.Errorf("response = %q, want %q", res.Message, want)
+		}
+
+		if elapsed := time.Since(start); want == "second" && elapsed < 50*time.Millisecond {
+			t.Errorf("second call returned after %v, before its delay", elapsed)
+		}
+	}
+
+	for _, want := range []codes.Code{codes.Unavailable, codes.Unimplemented} {
+		if _, err := client.SayHello(context.Background(), &examples.Request{}); status.Code(err) != want {
+			t.Errorf("err = %v, want %v", err, want)
+		}
+	}
+
+	if n := srv.Calls(sayHelloMethod); n != 4 {
+		t.Errorf("Calls = %d, want 4", n)
+	}
+}
+
+func TestScriptedServerStreamReply(t *testing.T) {
+	srv := NewScriptedServer(t)
+	srv.Script("/examples.Greeter/SayHelloStreamReply", Reply{Response: &examples.Response{Message: "only"}})
+	stream, err := examples.NewGreeterClient(srv.Conn()).SayHelloStreamReply(context.Background(), &examples.Request{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	} else if res.Message != "only" {
+		t.Errorf("response = %q, want %q", res.Message, "only")
+	}
+}
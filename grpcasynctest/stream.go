@@ -0,0 +1,66 @@
+// Package grpcasynctest provides helpers for testing code built on gRPC and
+// the grpcasync package.
+package grpcasynctest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ExpectStream reads from ch and fails the test unless it receives exactly
+// the values in want, in order, before ch is closed, waiting at most timeout
+// for each of them. Proto messages are compared with proto.Equal, other
+// values with reflect.DeepEqual.
+func ExpectStream[Res any](t testing.TB, ch <-chan Res, want []Res, timeout time.Duration) {
+	t.Helper()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for i := 0; ; i++ {
+		var got Res
+		var ok bool
+
+		select {
+		case got, ok = <-ch:
+		case <-timer.C:
+			t.Fatalf("timed out after %v waiting for message %d", timeout, i)
+			return
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		timer.Reset(timeout)
+
+		if !ok {
+			if i < len(want) {
+				t.Fatalf("stream closed after %d messages, want %d", i, len(want))
+			}
+
+			return
+		} else if i >= len(want) {
+			t.Fatalf("unexpected message %d: %v", i, got)
+			return
+		} else if !equal(got, want[i]) {
+			t.Fatalf("message %d = %v, want %v", i, got, want[i])
+			return
+		}
+	}
+}
+
+func equal(a, b any) bool {
+	if x, ok := a.(proto.Message); ok {
+		if y, ok := b.(proto.Message); ok {
+			return proto.Equal(x, y)
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
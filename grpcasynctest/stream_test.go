@@ -0,0 +1,86 @@
+package grpcasynctest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+)
+
+// recorder is a testing.TB recording failures instead of ending the test.
+// Like the helpers under test, callers return right after Fatalf.
+type recorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.failure = fmt.Sprintf(format, args...)
+}
+
+// responses builds the Greeter responses carrying msgs.
+func responses(msgs ...string) []*examples.Response {
+	res := make([]*examples.Response, len(msgs))
+
+	for i, msg := range msgs {
+		res[i] = &examples.Response{Message: msg}
+	}
+
+	return res
+}
+
+// feed returns a channel holding values, closed if closed is true.
+func feed[T any](values []T, closed bool) <-chan T {
+	ch := make(chan T, len(values))
+
+	for _, v := range values {
+		ch <- v
+	}
+
+	if closed {
+		close(ch)
+	}
+
+	return ch
+}
+
+func TestExpectStream(t *testing.T) {
+	want := responses("Hello 1: World", "Hello 2: World", "Hello 3: World")
+
+	tests := []struct {
+		name    string
+		got     <-chan *examples.Response
+		failure string
+	}{
+		{"match", feed(responses("Hello 1: World", "Hello 2: World", "Hello 3: World"), true), ""},
+		{"mismatch", feed(responses("Hello 1: World", "Hello 2: Moon", "Hello 3: World"), true),
+			`message 1 = message:"Hello 2: Moon", want message:"Hello 2: World"`},
+		{"short", feed(responses("Hello 1: World"), true), "stream closed after 1 messages, want 3"},
+		{"long", feed(responses("Hello 1: World", "Hello 2: World", "Hello 3: World", "Hello 4: World"), true),
+			`unexpected message 3: message:"Hello 4: World"`},
+		{"stalled", feed(responses("Hello 1: World"), false), "timed out after 50ms waiting for message 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			ExpectStream(r, tt.got, want, 50*time.Millisecond)
+
+			if r.failure != tt.failure {
+				t.Errorf("failure = %q, want %q", r.failure, tt.failure)
+			}
+		})
+	}
+}
+
+func TestExpectStreamPlainValues(t *testing.T) {
+	r := &recorder{TB: t}
+	ExpectStream(r, feed([]int{1, 2}, true), []int{1, 2}, time.Second)
+
+	if r.failure != "" {
+		t.Errorf("unexpected failure %q", r.failure)
+	}
+}
@@ -0,0 +1,46 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+)
+
+var (
+	// ErrStreamingNotAllowed is returned when a streaming method is called
+	// through a UnaryOnlyConn.
+	ErrStreamingNotAllowed = errors.New("grpcasync: streaming call on a unary-only connection")
+	// ErrUnaryNotAllowed is returned when a unary method is called through a
+	// StreamOnlyConn.
+	ErrUnaryNotAllowed = errors.New("grpcasync: unary call on a stream-only connection")
+)
+
+// UnaryOnlyConn wraps a connection so that only unary methods can be called
+// through it. Clients generated over it fail streaming calls right away with
+// ErrStreamingNotAllowed instead of reaching the transport.
+type UnaryOnlyConn struct {
+	grpc.ClientConnInterface
+}
+
+// NewStream implements grpc.ClientConnInterface, it always fails.
+func (c UnaryOnlyConn) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	return nil, fmt.Errorf("%w: %s", ErrStreamingNotAllowed, method)
+}
+
+// StreamOnlyConn wraps a connection so that only streaming methods can be
+// called through it, unary calls fail with ErrUnaryNotAllowed.
+type StreamOnlyConn struct {
+	grpc.ClientConnInterface
+}
+
+// Invoke implements grpc.ClientConnInterface, it always fails.
+func (c StreamOnlyConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	return fmt.Errorf("%w: %s", ErrUnaryNotAllowed, method)
+}
@@ -0,0 +1,43 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+)
+
+func TestUnaryOnlyConn(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	client := examples.NewGreeterClient(UnaryOnlyConn{conn})
+
+	if res, err := client.SayHello(context.Background(), &examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, World" {
+		t.Errorf("message = %q", res.Message)
+	}
+
+	if _, err := client.SayHelloDuplex(context.Background()); !errors.Is(err, ErrStreamingNotAllowed) {
+		t.Errorf("err = %v, want ErrStreamingNotAllowed", err)
+	}
+}
+
+func TestStreamOnlyConn(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	client := examples.NewGreeterClient(StreamOnlyConn{conn})
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{}); !errors.Is(err, ErrUnaryNotAllowed) {
+		t.Errorf("err = %v, want ErrUnaryNotAllowed", err)
+	}
+
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{Name: "World"})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello 1: World" {
+		t.Errorf("message = %q", res.Message)
+	}
+}
@@ -0,0 +1,195 @@
+package grpcasync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// hashReplicas is the number of points each backend occupies on the hash
+// ring, which evens out the share of keys each one receives.
+const hashReplicas = 100
+
+// HashedConn is a grpc.ClientConnInterface routing each call to one of
+// several backends by consistent hashing of a key derived from the call, so
+// that calls with the same key reach the same backend for cache affinity.
+// Adding or removing a backend only remaps the keys of its neighbors on the
+// ring.
+type HashedConn struct {
+	keyFn func(ctx context.Context, req any) string
+	opts  []grpc.DialOption
+
+	mu     sync.RWMutex
+	conns  map[string]*grpc.ClientConn
+	ring   []uint32
+	owners map[uint32]string
+}
+
+// ConnectHashed dials all addrs and returns a client of type T built by
+// factory over a HashedConn routing with keyFn. For streams, keyFn receives a
+// nil request since the stream is opened before any message is sent.
+func ConnectHashed[T any](
+	addrs []string,
+	keyFn func(ctx context.Context, req any) string,
+	factory func(grpc.ClientConnInterface) T,
+	opts ...grpc.DialOption,
+) (T, *HashedConn, error) {
+	conn := &HashedConn{
+		keyFn:  keyFn,
+		opts:   opts,
+		conns:  map[string]*grpc.ClientConn{},
+		owners: map[uint32]string{},
+	}
+
+	for _, addr := range addrs {
+		if err := conn.AddBackend(addr); err != nil {
+			conn.Close()
+			var zero T
+			return zero, nil, err
+		}
+	}
+
+	return factory(conn), conn, nil
+}
+
+// AddBackend dials addr and adds it to the ring.
+func (c *HashedConn) AddBackend(addr string) error {
+	target, err := NormalizeTarget(addr)
+
+	if err != nil {
+		return err
+	}
+
+	cc, err := grpc.Dial(target, c.opts...)
+
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.conns[addr]; ok {
+		cc.Close()
+		return nil
+	}
+
+	c.conns[addr] = cc
+
+	for i := 0; i < hashReplicas; i++ {
+		h := hashKey(addr + "#" + strconv.Itoa(i))
+		c.owners[h] = addr
+		c.ring = append(c.ring, h)
+	}
+
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i] < c.ring[j] })
+	return nil
+}
+
+// RemoveBackend removes addr from the ring and closes its connection.
+func (c *HashedConn) RemoveBackend(addr string) error {
+	c.mu.Lock()
+	cc, ok := c.conns[addr]
+
+	if ok {
+		delete(c.conns, addr)
+		ring := c.ring[:0]
+
+		for _, h := range c.ring {
+			if c.owners[h] == addr {
+				delete(c.owners, h)
+			} else {
+				ring = append(ring, h)
+			}
+		}
+
+		c.ring = ring
+	}
+
+	c.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return cc.Close()
+}
+
+// Close closes the connections to all backends.
+func (c *HashedConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+
+	for _, cc := range c.conns {
+		errs = append(errs, cc.Close())
+	}
+
+	c.conns = map[string]*grpc.ClientConn{}
+	c.ring = nil
+	c.owners = map[uint32]string{}
+
+	return errors.Join(errs...)
+}
+
+// Invoke implements grpc.ClientConnInterface.
+func (c *HashedConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	cc, err := c.pick(c.keyFn(ctx, args))
+
+	if err != nil {
+		return err
+	}
+
+	return cc.Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream implements grpc.ClientConnInterface.
+func (c *HashedConn) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	cc, err := c.pick(c.keyFn(ctx, nil))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cc.NewStream(ctx, desc, method, opts...)
+}
+
+func (c *HashedConn) pick(key string) (*grpc.ClientConn, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.ring) == 0 {
+		return nil, status.Error(codes.Unavailable, "grpcasync: no backends available")
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i] >= h })
+
+	if i == len(c.ring) {
+		i = 0
+	}
+
+	return c.conns[c.owners[c.ring[i]]], nil
+}
+
+// hashKey hashes with SHA-256 rather than FNV, which places similar keys such
+// as "addr#1" and "addr#2" too close to each other for the ring to be
+// balanced.
+func hashKey(key string) uint32 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
@@ -0,0 +1,88 @@
+package grpcasync
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestConnectHashed(t *testing.T) {
+	dialer := serveBackends(t, "a", "b", "c")
+	addrs := []string{"passthrough:///a", "passthrough:///b", "passthrough:///c"}
+	keyFn := func(ctx context.Context, req any) string {
+		return req.(*examples.Request).Name
+	}
+
+	client, conn, err := ConnectHashed(addrs, keyFn, examples.NewGreeterClient,
+		grpc.WithTransportCredentials(insecure.NewCredentials()), dialer)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	backendOf := func(key string) string {
+		t.Helper()
+		res, err := client.SayHello(context.Background(), &examples.Request{Name: key})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res.Message
+	}
+
+	owners := map[string]string{}
+	counts := map[string]int{}
+
+	for i := 0; i < 60; i++ {
+		key := "user-" + strconv.Itoa(i)
+		owners[key] = backendOf(key)
+		counts[owners[key]]++
+	}
+
+	if len(counts) != 3 {
+		t.Errorf("keys spread over %v, want all three backends", counts)
+	}
+
+	// The same key always reaches the same backend.
+	for key, owner := range owners {
+		if got := backendOf(key); got != owner {
+			t.Errorf("key %s moved from %s to %s", key, owner, got)
+		}
+	}
+
+	// Removing a backend only remaps its own keys.
+	if err := conn.RemoveBackend("passthrough:///b"); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, owner := range owners {
+		if got := backendOf(key); owner != "b" && got != owner {
+			t.Errorf("key %s of %s remapped to %s", key, owner, got)
+		} else if got == "b" {
+			t.Errorf("key %s still reaches the removed backend", key)
+		}
+	}
+}
+
+func TestHashedConnNoBackends(t *testing.T) {
+	client, conn, err := ConnectHashed(nil, func(context.Context, any) string { return "" }, examples.NewGreeterClient)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("err = %v, want Unavailable", err)
+	}
+}
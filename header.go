@@ -0,0 +1,51 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ErrHeaderSent is returned by SendHeaderOnce when the header of the stream
+// has already been sent through it.
+var ErrHeaderSent = errors.New("grpcasync: header already sent")
+
+var sentHeaders sync.Map // map[grpc.ServerStream]struct{}
+
+// SendHeaderOnce sends md as the header of stream. Headers can only be sent
+// once and must precede the first message, so any further call for the same
+// stream returns ErrHeaderSent instead of the transport's opaque error.
+func SendHeaderOnce(stream grpc.ServerStream, md metadata.MD) error {
+	if _, loaded := sentHeaders.LoadOrStore(stream, struct{}{}); loaded {
+		return ErrHeaderSent
+	}
+
+	context.AfterFunc(stream.Context(), func() {
+		sentHeaders.Delete(stream)
+	})
+
+	return stream.SendHeader(md)
+}
+
+// CallWithMeta runs a unary call and also returns the header and trailer
+// metadata sent by the server, sparing the grpc.Header and grpc.Trailer
+// plumbing:
+//
+//	res, header, trailer, err := grpcasync.CallWithMeta(
+//		func(opts ...grpc.CallOption) (*examples.Response, error) {
+//			return client.SayHello(ctx, req, opts...)
+//		})
+//
+// The metadata is returned even when the call fails, as far as it was
+// received.
+func CallWithMeta[Res any](
+	invoke func(opts ...grpc.CallOption) (*Res, error),
+) (*Res, metadata.MD, metadata.MD, error) {
+	var header, trailer metadata.MD
+	res, err := invoke(grpc.Header(&header), grpc.Trailer(&trailer))
+
+	return res, header, trailer, err
+}
@@ -0,0 +1,96 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// headerGreeter sends a header twice before streaming its replies and
+// reports the error of the second attempt.
+type headerGreeter struct {
+	greeter
+	second chan error
+}
+
+func (g *headerGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	if err := SendHeaderOnce(stream, metadata.Pairs("x-version", "1")); err != nil {
+		return err
+	}
+
+	g.second <- SendHeaderOnce(stream, metadata.Pairs("x-version", "2"))
+	return g.greeter.SayHelloStreamReply(req, stream)
+}
+
+func TestSendHeaderOnce(t *testing.T) {
+	impl := &headerGreeter{second: make(chan error, 1)}
+	client, _ := startGreeter(t, impl, nil)
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{Name: "World"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := stream.Header()
+
+	if err != nil {
+		t.Fatal(err)
+	} else if got := header.Get("x-version"); len(got) != 1 || got[0] != "1" {
+		t.Errorf("x-version = %v, want [1]", got)
+	}
+
+	if err := <-impl.second; !errors.Is(err, ErrHeaderSent) {
+		t.Errorf("second call err = %v, want ErrHeaderSent", err)
+	}
+
+	if _, err := stream.Recv(); err != nil {
+		t.Errorf("stream failed after the header: %v", err)
+	}
+}
+
+func TestCallWithMeta(t *testing.T) {
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		grpc.SetHeader(ctx, metadata.Pairs("x-version", "1"))
+		grpc.SetTrailer(ctx, metadata.Pairs("x-cost", "3"))
+
+		if req.Name == "" {
+			return nil, status.Error(codes.InvalidArgument, "empty name")
+		}
+
+		return &examples.Response{Message: "Hello, " + req.Name}, nil
+	}}, nil)
+
+	tests := []struct {
+		name     string
+		wantCode codes.Code
+	}{
+		{"World", codes.OK},
+		{"", codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		res, header, trailer, err := CallWithMeta(func(opts ...grpc.CallOption) (*examples.Response, error) {
+			return client.SayHello(context.Background(), &examples.Request{Name: tt.name}, opts...)
+		})
+
+		if code := status.Code(err); code != tt.wantCode {
+			t.Errorf("%q: code = %v, want %v", tt.name, code, tt.wantCode)
+		} else if err == nil && res.Message != "Hello, World" {
+			t.Errorf("%q: message = %q", tt.name, res.Message)
+		}
+
+		if got := header.Get("x-version"); len(got) != 1 || got[0] != "1" {
+			t.Errorf("%q: header x-version = %v, want [1]", tt.name, got)
+		}
+
+		if got := trailer.Get("x-cost"); len(got) != 1 || got[0] != "3" {
+			t.Errorf("%q: trailer x-cost = %v, want [3]", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// The phases of ConnectHealthy, matched with errors.Is on its errors.
+var (
+	ErrDialFailed = errors.New("grpcasync: dial failed")
+	ErrNotHealthy = errors.New("grpcasync: service not healthy")
+)
+
+// ConnectHealthy dials addr, waits until the health service of the server
+// reports service as SERVING and returns a client of type T built by factory
+// along with the function closing its connection. Both phases are bounded by
+// ctx, so startup code can require a dependency to be up within a single
+// timeout. Errors wrap ErrDialFailed or ErrNotHealthy depending on the phase
+// that failed. The server must implement the Watch method of the standard
+// health service.
+func ConnectHealthy[T any](
+	ctx context.Context,
+	addr string,
+	service string,
+	factory func(grpc.ClientConnInterface) T,
+	opts ...grpc.DialOption,
+) (T, func() error, error) {
+	var zero T
+	target, err := NormalizeTarget(addr)
+
+	if err != nil {
+		return zero, nil, fmt.Errorf("%w: %w", ErrDialFailed, err)
+	}
+
+	opts = append(opts[:len(opts):len(opts)], grpc.WithBlock(), grpc.WithReturnConnectionError())
+	conn, err := grpc.DialContext(ctx, target, opts...)
+
+	if err != nil {
+		return zero, nil, fmt.Errorf("%w: %w", ErrDialFailed, err)
+	}
+
+	if err := waitServing(ctx, conn, service); err != nil {
+		conn.Close()
+		return zero, nil, fmt.Errorf("%w: %w", ErrNotHealthy, err)
+	}
+
+	return factory(conn), conn.Close, nil
+}
+
+func waitServing(ctx context.Context, conn *grpc.ClientConn, service string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req := &grpc_health_v1.HealthCheckRequest{Service: service}
+	stream, err := grpc_health_v1.NewHealthClient(conn).Watch(ctx, req)
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		res, err := stream.Recv()
+
+		if err != nil {
+			return err
+		} else if res.Status == grpc_health_v1.HealthCheckResponse_SERVING {
+			return nil
+		}
+	}
+}
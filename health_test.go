@@ -0,0 +1,85 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startHealthyGreeter serves the Greeter along with a health service, which
+// initially reports it as NOT_SERVING, and returns the dial options reaching
+// the server.
+func startHealthyGreeter(t *testing.T) (*health.Server, []grpc.DialOption) {
+	t.Helper()
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("examples.Greeter", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	srv := grpc.NewServer()
+	examples.RegisterGreeterServer(srv, &greeter{})
+	grpc_health_v1.RegisterHealthServer(srv, healthSrv)
+	lis := serve(t, srv)
+
+	// The spare capacity lets an append to the options alias caller memory.
+	opts := make([]grpc.DialOption, 0, 4)
+	opts = append(opts,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	)
+
+	return healthSrv, opts
+}
+
+func TestConnectHealthy(t *testing.T) {
+	healthSrv, opts := startHealthyGreeter(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	time.AfterFunc(50*time.Millisecond, func() {
+		healthSrv.SetServingStatus("examples.Greeter", grpc_health_v1.HealthCheckResponse_SERVING)
+	})
+
+	client, closeConn, err := ConnectHealthy(ctx, "passthrough:///bufconn", "examples.Greeter", examples.NewGreeterClient, opts...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer closeConn()
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("connected after %v, while the service was NOT_SERVING", elapsed)
+	}
+
+	if res, err := client.SayHello(ctx, &examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, World" {
+		t.Errorf("response = %q", res.Message)
+	}
+
+	if spare := opts[:cap(opts)][len(opts):]; spare[0] != nil {
+		t.Error("ConnectHealthy appended to the dial options of the caller")
+	}
+}
+
+func TestConnectHealthyNotServing(t *testing.T) {
+	_, opts := startHealthyGreeter(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, _, err := ConnectHealthy(ctx, "passthrough:///bufconn", "examples.Greeter", examples.NewGreeterClient, opts...)
+
+	if !errors.Is(err, ErrNotHealthy) {
+		t.Errorf("err = %v, want ErrNotHealthy", err)
+	}
+}
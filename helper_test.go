@@ -0,0 +1,202 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"github.com/ayonli/grpc-async/grpcasynctest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// greeter is the Greeter of test/main.go, the reference implementation the
+// tests run against.
+type greeter struct {
+	examples.UnimplementedGreeterServer
+}
+
+func (g *greeter) SayHello(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	return &examples.Response{Message: "Hello, " + req.Name}, nil
+}
+
+func (g *greeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	for _, n := range []string{"1", "2", "3"} {
+		if err := stream.Send(&examples.Response{Message: "Hello " + n + ": " + req.Name}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *greeter) SayHelloStreamRequest(stream examples.Greeter_SayHelloStreamRequestServer) error {
+	var names []string
+
+	for {
+		req, err := stream.Recv()
+
+		if err == io.EOF {
+			return stream.SendAndClose(&examples.Response{Message: "Hello, " + strings.Join(names, ", ")})
+		} else if err != nil {
+			return err
+		}
+
+		names = append(names, req.Name)
+	}
+}
+
+func (g *greeter) SayHelloDuplex(stream examples.Greeter_SayHelloDuplexServer) error {
+	for {
+		req, err := stream.Recv()
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := stream.Send(&examples.Response{Message: "Hello, " + req.Name}); err != nil {
+			return err
+		}
+	}
+}
+
+// namedGreeter answers SayHello with its name, which tells the tests which
+// of several backends served a call.
+type namedGreeter struct {
+	greeter
+	name string
+}
+
+func (g *namedGreeter) SayHello(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	return &examples.Response{Message: g.name}, nil
+}
+
+// funcGreeter answers SayHello with a function, the other methods like
+// greeter.
+type funcGreeter struct {
+	greeter
+	sayHello func(ctx context.Context, req *examples.Request) (*examples.Response, error)
+}
+
+func (g *funcGreeter) SayHello(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	return g.sayHello(ctx, req)
+}
+
+// serveBackends serves a namedGreeter per name and returns a dialer routing
+// each name, used as the address, to its backend.
+func serveBackends(t testing.TB, names ...string) grpc.DialOption {
+	t.Helper()
+	listeners := map[string]*bufconn.Listener{}
+
+	for _, name := range names {
+		srv := grpc.NewServer()
+		examples.RegisterGreeterServer(srv, &namedGreeter{name: name})
+		listeners[name] = serve(t, srv)
+	}
+
+	return grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return listeners[addr].DialContext(ctx)
+	})
+}
+
+// serve serves srv over an in-memory listener until the test ends.
+func serve(t testing.TB, srv *grpc.Server) *bufconn.Listener {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return lis
+}
+
+// dial connects to lis, the connection is closed when the test ends.
+func dial(t testing.TB, lis *bufconn.Listener, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	}, opts...)
+
+	conn, err := grpc.Dial("passthrough:///bufconn", opts...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// startGreeter serves impl with the server options sopts and returns a
+// client connected with the dial options copts.
+func startGreeter(
+	t testing.TB,
+	impl examples.GreeterServer,
+	sopts []grpc.ServerOption,
+	copts ...grpc.DialOption,
+) (examples.GreeterClient, *grpc.ClientConn) {
+	t.Helper()
+	srv := grpc.NewServer(sopts...)
+	examples.RegisterGreeterServer(srv, impl)
+	conn := dial(t, serve(t, srv), copts...)
+
+	return examples.NewGreeterClient(conn), conn
+}
+
+// assertNoLeak connects conn before running fn with
+// grpcasynctest.AssertNoLeak, so that the goroutines of the connection are
+// not taken for leaks of fn. A call to a method the server lacks makes sure
+// both ends have set up the connection fully, without reaching the greeter.
+func assertNoLeak(t *testing.T, conn *grpc.ClientConn, fn func()) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := conn.Invoke(ctx, "/grpcasync.Test/Connect", &examples.Request{}, &examples.Response{}, grpc.WaitForReady(true))
+
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("connecting: %v", err)
+	}
+
+	grpcasynctest.AssertNoLeak(t, time.Second, fn)
+}
+
+// streamReplyDesc and friends describe the streams of the Greeter for the
+// helpers working on grpc.ClientStream.
+var (
+	streamReplyDesc   = &grpc.StreamDesc{ServerStreams: true}
+	streamRequestDesc = &grpc.StreamDesc{ClientStreams: true}
+	duplexDesc        = &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+)
+
+const (
+	sayHelloMethod      = "/examples.Greeter/SayHello"
+	streamReplyMethod   = "/examples.Greeter/SayHelloStreamReply"
+	streamRequestMethod = "/examples.Greeter/SayHelloStreamRequest"
+	duplexMethod        = "/examples.Greeter/SayHelloDuplex"
+)
+
+// openStreamReply opens SayHelloStreamReply for name on conn.
+func openStreamReply(ctx context.Context, conn grpc.ClientConnInterface, name string) (grpc.ClientStream, error) {
+	stream, err := conn.NewStream(ctx, streamReplyDesc, streamReplyMethod)
+
+	if err != nil {
+		return nil, err
+	} else if err := stream.SendMsg(&examples.Request{Name: name}); err != nil {
+		return nil, err
+	}
+
+	return stream, stream.CloseSend()
+}
@@ -0,0 +1,137 @@
+package grpcasync
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// IdleStreamTimeoutInterceptor returns a server interceptor that ends
+// streams on which no message has been received or sent for d, e.g. to drop
+// duplex clients that open a stream and never use it. The context of the
+// handler is canceled at that point and its pending receives fail with
+// DeadlineExceeded, which is also how the stream ends once the handler has
+// returned.
+func IdleStreamTimeoutInterceptor(d time.Duration) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, cancel := context.WithCancel(stream.Context())
+		defer cancel()
+
+		idle := &idleServerStream{
+			ServerStream: stream,
+			ctx:          ctx,
+			timeout:      d,
+			recvs:        make(chan any),
+			received:     make(chan error, 1),
+			done:         make(chan struct{}),
+		}
+		idle.timer = time.AfterFunc(d, func() {
+			idle.expired.Store(true)
+			cancel()
+		})
+		defer idle.timer.Stop()
+		defer close(idle.done)
+
+		err := handler(srv, idle)
+
+		if idle.expired.Load() {
+			return idle.ctxErr(nil)
+		}
+
+		return err
+	}
+}
+
+type idleServerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+
+	// The messages to receive are handed to a goroutine running for as long
+	// as the stream, started by the first RecvMsg.
+	recvOnce sync.Once
+	recvs    chan any
+	received chan error
+	done     chan struct{}
+}
+
+func (s *idleServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *idleServerStream) SendMsg(m any) error {
+	s.touch()
+	return s.ServerStream.SendMsg(m)
+}
+
+// RecvMsg waits for the next message in the background, as canceling the
+// context of the handler does not interrupt the receive of the transport.
+// If the stream goes idle first, the abandoned receive may still write to m
+// until the transport gives up on it when the handler returns, so m must not
+// be used after such a failure.
+func (s *idleServerStream) RecvMsg(m any) error {
+	if err := s.ctx.Err(); err != nil {
+		return s.ctxErr(err)
+	}
+
+	s.recvOnce.Do(func() { go s.receive() })
+
+	select {
+	case s.recvs <- m:
+	case <-s.ctx.Done():
+		return s.ctxErr(s.ctx.Err())
+	}
+
+	select {
+	case err := <-s.received:
+		if err != nil {
+			return err
+		}
+
+		s.touch()
+		return nil
+	case <-s.ctx.Done():
+		return s.ctxErr(s.ctx.Err())
+	}
+}
+
+// receive receives the messages handed over by RecvMsg until the handler
+// returns.
+func (s *idleServerStream) receive() {
+	for {
+		select {
+		case m := <-s.recvs:
+			s.received <- s.ServerStream.RecvMsg(m)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *idleServerStream) ctxErr(err error) error {
+	if s.expired.Load() {
+		return status.Errorf(codes.DeadlineExceeded, "grpcasync: stream idle for %v", s.timeout)
+	}
+
+	return status.FromContextError(err).Err()
+}
+
+// touch restarts the idle timer, unless it has already fired, in which case
+// the stream is being dropped anyway.
+func (s *idleServerStream) touch() {
+	if s.timer.Stop() {
+		s.timer.Reset(s.timeout)
+	}
+}
@@ -0,0 +1,97 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIdleStreamTimeoutInterceptor(t *testing.T) {
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.StreamInterceptor(IdleStreamTimeoutInterceptor(100 * time.Millisecond)),
+	})
+	stream, err := client.SayHelloDuplex(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = stream.Recv()
+
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	} else if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("dropped after %v, want about 100ms", elapsed)
+	}
+}
+
+func TestIdleStreamTimeoutInterceptorActive(t *testing.T) {
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.StreamInterceptor(IdleStreamTimeoutInterceptor(100 * time.Millisecond)),
+	})
+	stream, err := client.SayHelloDuplex(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stream outlives the timeout, but every message resets it.
+	for i := 0; i < 5; i++ {
+		time.Sleep(40 * time.Millisecond)
+
+		if err := stream.Send(&examples.Request{Name: "World"}); err != nil {
+			t.Fatal(err)
+		} else if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	} else if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("err = %v, want the stream to end normally", err)
+	}
+}
+
+func BenchmarkIdleStreamTimeoutInterceptor(b *testing.B) {
+	for _, idle := range []bool{false, true} {
+		name := "plain"
+		var sopts []grpc.ServerOption
+
+		if idle {
+			name = "idle"
+			sopts = append(sopts, grpc.StreamInterceptor(IdleStreamTimeoutInterceptor(time.Minute)))
+		}
+
+		b.Run(name, func(b *testing.B) {
+			client, _ := startGreeter(b, &greeter{}, sopts)
+			stream, err := client.SayHelloDuplex(context.Background())
+
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := stream.Send(&examples.Request{Name: "World"}); err != nil {
+					b.Fatal(err)
+				} else if _, err := stream.Recv(); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.StopTimer()
+			stream.CloseSend()
+			stream.Recv()
+		})
+	}
+}
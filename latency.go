@@ -0,0 +1,116 @@
+package grpcasync
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type latencyReservoir struct {
+	samples []time.Duration
+	count   int
+}
+
+// LatencyCollector records the latency of the calls a server handles and
+// reports percentiles per method, e.g. for a test to assert that the p99 of a
+// method stays under a threshold. Memory is bounded by reservoir sampling.
+// Install its interceptors on the server. The zero value is ready to use.
+type LatencyCollector struct {
+	// ReservoirSize is the number of durations kept per method, sampled
+	// uniformly from all calls, 1024 if zero. It must not be changed once
+	// calls are recorded.
+	ReservoirSize int
+
+	mu      sync.Mutex
+	methods map[string]*latencyReservoir
+}
+
+// Percentile returns the p-th percentile, from 0 to 100, of the latency of
+// method, or 0 if it has not been called.
+func (c *LatencyCollector) Percentile(method string, p float64) time.Duration {
+	c.mu.Lock()
+	r, ok := c.methods[method]
+	var samples []time.Duration
+
+	if ok {
+		samples = append(samples, r.samples...)
+	}
+
+	c.mu.Unlock()
+
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(math.Ceil(p/100*float64(len(samples)))) - 1
+
+	return samples[min(max(i, 0), len(samples)-1)]
+}
+
+// UnaryInterceptor returns a server interceptor recording unary calls.
+func (c *LatencyCollector) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		timer := CallTimerFromContext(ctx)
+		res, err := handler(ctx, req)
+		c.record(info.FullMethod, timer.Stop())
+
+		return res, err
+	}
+}
+
+// StreamInterceptor returns a server interceptor recording the total
+// duration of streams.
+func (c *LatencyCollector) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		timer := CallTimerFromContext(stream.Context())
+		err := handler(srv, stream)
+		c.record(info.FullMethod, timer.Stop())
+
+		return err
+	}
+}
+
+func (c *LatencyCollector) record(method string, dur time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.methods == nil {
+		c.methods = map[string]*latencyReservoir{}
+	}
+
+	r, ok := c.methods[method]
+
+	if !ok {
+		r = &latencyReservoir{}
+		c.methods[method] = r
+	}
+
+	r.count++
+	size := c.ReservoirSize
+
+	if size == 0 {
+		size = 1024
+	}
+
+	if len(r.samples) < size {
+		r.samples = append(r.samples, dur)
+	} else if i := rand.Intn(r.count); i < len(r.samples) {
+		r.samples[i] = dur
+	}
+}
@@ -0,0 +1,76 @@
+package grpcasync
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+)
+
+func TestLatencyCollector(t *testing.T) {
+	collector := &LatencyCollector{}
+	client, _ := startGreeter(t, &sleepyGreeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(collector.UnaryInterceptor()),
+	})
+
+	// 11 fast calls and 9 slow ones, made concurrently, put the median on
+	// the fast side and the p90 on the slow one.
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		name := "1ms"
+
+		if i >= 11 {
+			name = "50ms"
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := client.SayHello(context.Background(), &examples.Request{Name: name}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if median := collector.Percentile(sayHelloMethod, 50); median <= 0 || median >= 50*time.Millisecond {
+		t.Errorf("median = %v, want the latency of a fast call", median)
+	}
+
+	if p90 := collector.Percentile(sayHelloMethod, 90); p90 < 50*time.Millisecond {
+		t.Errorf("p90 = %v, want the latency of a slow call", p90)
+	}
+
+	if d := collector.Percentile(streamReplyMethod, 50); d != 0 {
+		t.Errorf("median of a method never called = %v, want 0", d)
+	}
+}
+
+func TestLatencyCollectorReservoirSize(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want int
+	}{
+		{"default", 0, 1024},
+		{"custom", 16, 16},
+	}
+
+	for _, tt := range tests {
+		collector := &LatencyCollector{ReservoirSize: tt.size}
+
+		for i := 0; i < 2000; i++ {
+			collector.record(sayHelloMethod, time.Duration(i))
+		}
+
+		if n := len(collector.methods[sayHelloMethod].samples); n != tt.want {
+			t.Errorf("%s: kept %d samples, want %d", tt.name, n, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package grpcasync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDMetadataKey is the metadata key LoggerInjectionInterceptor reads
+// the request ID from.
+const RequestIDMetadataKey = "x-request-id"
+
+type loggerKey struct{}
+
+// LoggerInjectionInterceptor returns a server interceptor that stores in the
+// context of each unary call a child of base logging the method and the
+// request ID, for handlers to retrieve with LoggerFromContext. Calls without
+// a request ID in their metadata are given a random one.
+func LoggerInjectionInterceptor(base *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(withLogger(ctx, base, info.FullMethod), req)
+	}
+}
+
+// LoggerInjectionStreamInterceptor is the streaming variant of
+// LoggerInjectionInterceptor.
+func LoggerInjectionStreamInterceptor(base *slog.Logger) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx := withLogger(stream.Context(), base, info.FullMethod)
+		return handler(srv, &contextServerStream{stream, ctx})
+	}
+}
+
+// LoggerFromContext returns the logger stored by LoggerInjectionInterceptor,
+// or slog.Default() if there is none.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+func withLogger(ctx context.Context, base *slog.Logger, method string) context.Context {
+	var id string
+
+	if values := metadata.ValueFromIncomingContext(ctx, RequestIDMetadataKey); len(values) > 0 {
+		id = values[0]
+	} else {
+		buf := make([]byte, 8)
+		rand.Read(buf)
+		id = hex.EncodeToString(buf)
+	}
+
+	logger := base.With(slog.String("method", method), slog.String("request_id", id))
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// contextServerStream overrides the context of a server stream, which is the
+// only way for a stream interceptor to pass values to the handler.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,69 @@
+package grpcasync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLoggerInjectionInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	records := make(chan map[string]any, 1)
+
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		buf.Reset()
+		LoggerFromContext(ctx).Info("greeting")
+		var record map[string]any
+
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			return nil, err
+		}
+
+		records <- record
+		return &examples.Response{Message: "Hello, " + req.Name}, nil
+	}}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(LoggerInjectionInterceptor(slog.New(slog.NewJSONHandler(&buf, nil)))),
+	})
+
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{"forwarded request id", "req-1"},
+		{"generated request id", ""},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+
+		if tt.id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, tt.id)
+		}
+
+		if _, err := client.SayHello(ctx, &examples.Request{Name: "World"}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		record := <-records
+
+		if record["method"] != sayHelloMethod {
+			t.Errorf("%s: method = %v, want %s", tt.name, record["method"], sayHelloMethod)
+		}
+
+		if id, _ := record["request_id"].(string); id == "" || (tt.id != "" && id != tt.id) {
+			t.Errorf("%s: request_id = %q, want %q", tt.name, id, tt.id)
+		}
+	}
+}
+
+func TestLoggerFromContextDefault(t *testing.T) {
+	if logger := LoggerFromContext(context.Background()); logger != slog.Default() {
+		t.Error("got a logger other than slog.Default() without the interceptor")
+	}
+}
@@ -0,0 +1,109 @@
+package grpcasync
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+type requestMemoKey struct{}
+
+// RequestMemo memoizes the results of the downstream unary calls made while
+// handling one inbound request, so that calling the same method with the same
+// request several times only goes to the network once. It is attached to the
+// context by WithRequestMemo or RequestMemoInterceptor and used by
+// RequestMemoClientInterceptor. Failed calls are not memoized.
+type RequestMemo struct {
+	group singleflight.Group
+
+	mu      sync.Mutex
+	results map[string]proto.Message
+}
+
+// WithRequestMemo returns a context carrying a new RequestMemo.
+func WithRequestMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestMemoKey{}, &RequestMemo{results: map[string]proto.Message{}})
+}
+
+// RequestMemoInterceptor returns a server interceptor attaching a new
+// RequestMemo to the context of each unary call.
+func RequestMemoInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		return handler(WithRequestMemo(ctx), req)
+	}
+}
+
+// RequestMemoClientInterceptor returns a client interceptor serving unary
+// calls from the RequestMemo of their context, keyed by method and request.
+// Calls whose context carries no memo, and non-proto calls, are sent as is.
+func RequestMemoClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		memo, ok := ctx.Value(requestMemoKey{}).(*RequestMemo)
+		reqMsg, isReq := req.(proto.Message)
+		msg, isReply := reply.(proto.Message)
+
+		if !ok || !isReq || !isReply {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		data, err := deterministic.Marshal(reqMsg)
+
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		key := method + "\x00" + string(data)
+		shared, err, _ := memo.group.Do(key, func() (any, error) {
+			if res := memo.get(key); res != nil {
+				return res, nil
+			}
+
+			res := msg.ProtoReflect().New().Interface()
+
+			if err := invoker(ctx, method, req, res, cc, opts...); err != nil {
+				return nil, err
+			}
+
+			memo.put(key, res)
+			return res, nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		proto.Reset(msg)
+		proto.Merge(msg, shared.(proto.Message))
+
+		return nil
+	}
+}
+
+func (m *RequestMemo) get(key string) proto.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.results[key]
+}
+
+func (m *RequestMemo) put(key string, res proto.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.results[key] = res
+}
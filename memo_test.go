@@ -0,0 +1,62 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+)
+
+func TestRequestMemo(t *testing.T) {
+	downstream := &countingGreeter{}
+	backend, _ := startGreeter(t, downstream, nil, grpc.WithUnaryInterceptor(RequestMemoClientInterceptor()))
+
+	// The frontend calls the backend twice with the same request, and once
+	// with another one, to handle each of its requests.
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		var message string
+
+		for _, name := range []string{req.Name, req.Name, "Other"} {
+			res, err := backend.SayHello(ctx, &examples.Request{Name: name})
+
+			if err != nil {
+				return nil, err
+			}
+
+			message += res.Message + ";"
+		}
+
+		return &examples.Response{Message: message}, nil
+	}}, []grpc.ServerOption{grpc.UnaryInterceptor(RequestMemoInterceptor())})
+
+	for i, want := range []int32{2, 4} {
+		res, err := client.SayHello(context.Background(), &examples.Request{Name: "World"})
+
+		if err != nil {
+			t.Fatal(err)
+		} else if res.Message != "Hello, World;Hello, World;Hello, Other;" {
+			t.Errorf("response = %q", res.Message)
+		}
+
+		// Each inbound request has a memo of its own.
+		if n := downstream.calls.Load(); n != want {
+			t.Errorf("after request %d: %d downstream calls, want %d", i+1, n, want)
+		}
+	}
+}
+
+func TestRequestMemoWithoutMemo(t *testing.T) {
+	downstream := &countingGreeter{}
+	backend, _ := startGreeter(t, downstream, nil, grpc.WithUnaryInterceptor(RequestMemoClientInterceptor()))
+
+	for i := 0; i < 2; i++ {
+		if _, err := backend.SayHello(context.Background(), &examples.Request{Name: "World"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := downstream.calls.Load(); n != 2 {
+		t.Errorf("%d downstream calls without a memo, want 2", n)
+	}
+}
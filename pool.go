@@ -0,0 +1,140 @@
+package grpcasync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Pool bounds the number of goroutines the stream helpers run when given
+// WithWorkerPool. Each helper call holds workers for as long as its stream
+// or channel is being pumped, and a helper that finds no free worker waits
+// for one, or until its context is done, before it returns.
+//
+// A pool trades throughput for a fixed goroutine count: with many concurrent
+// streams, calls queue for workers instead of each starting its own, so a
+// pool sized below the number of streams meant to run at once serializes
+// them. Exchange takes its two workers together and fails on a pool of one.
+// MultiplexDuplex holds one worker for as long as its stream runs, plus one
+// per open channel, so a pool too small for the channels opened at once
+// stalls OpenChannel until the stream ends.
+//
+// Only the client stream helpers taking a StreamOption use a pool. The
+// server side helpers, such as OrderedDuplex, BatchingSender and
+// IdleStreamTimeoutInterceptor, run their goroutines directly, as these are
+// already bounded by the streams the server accepts.
+type Pool struct {
+	size int
+
+	mu   sync.Mutex
+	busy int
+	// freed is closed and replaced whenever workers are released.
+	freed chan struct{}
+}
+
+// NewPool returns a Pool of size workers, at least 1.
+func NewPool(size int) *Pool {
+	return &Pool{size: max(size, 1), freed: make(chan struct{})}
+}
+
+// Go runs fn on a goroutine once a worker is free, waiting for one if
+// needed. A nil Pool runs fn on a new goroutine right away.
+func (p *Pool) Go(fn func()) {
+	p.GoCtx(context.Background(), fn)
+}
+
+// GoCtx runs each of fns on a goroutine of its own once there is a free
+// worker for all of them at once, waiting for them if needed. If ctx is done
+// first, none of fns is run and ctx.Err() is returned. An error is also
+// returned if the pool has fewer workers than fns. A nil Pool runs fns on new
+// goroutines right away.
+func (p *Pool) GoCtx(ctx context.Context, fns ...func()) error {
+	if p == nil {
+		for _, fn := range fns {
+			go fn()
+		}
+
+		return nil
+	} else if err := p.acquire(ctx, len(fns)); err != nil {
+		return err
+	}
+
+	for _, fn := range fns {
+		go func(fn func()) {
+			defer p.release()
+			fn()
+		}(fn)
+	}
+
+	return nil
+}
+
+// Running returns the number of busy workers.
+func (p *Pool) Running() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.busy
+}
+
+func (p *Pool) acquire(ctx context.Context, n int) error {
+	if n > p.size {
+		return fmt.Errorf("grpcasync: %d workers needed at once, more than the %d of the pool", n, p.size)
+	}
+
+	for {
+		p.mu.Lock()
+
+		if p.busy+n <= p.size {
+			p.busy += n
+			p.mu.Unlock()
+			return nil
+		}
+
+		freed := p.freed
+		p.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *Pool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.busy--
+	close(p.freed)
+	p.freed = make(chan struct{})
+}
+
+// StreamOption configures the stream helpers that run goroutines of their
+// own, such as TeeStream, Paginate, ResumableStream, MultiplexDuplex,
+// Exchange and CloseAndRecvCtx.
+type StreamOption func(*streamOptions)
+
+type streamOptions struct {
+	pool *Pool
+}
+
+// WithWorkerPool makes a stream helper run its goroutines on the workers of
+// p rather than on goroutines of its own.
+func WithWorkerPool(p *Pool) StreamOption {
+	return func(o *streamOptions) {
+		o.pool = p
+	}
+}
+
+// workerPool returns the pool set by opts, nil if there is none.
+func workerPool(opts []StreamOption) *Pool {
+	var o streamOptions
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o.pool
+}
@@ -0,0 +1,364 @@
+package grpcasync
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPool(t *testing.T) {
+	pool := NewPool(2)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	running, peak := 0, 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		pool.Go(func() {
+			defer wg.Done()
+
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+		})
+	}
+
+	wg.Wait()
+
+	if peak != 2 {
+		t.Errorf("%d functions ran at once, want 2", peak)
+	}
+}
+
+func TestWithWorkerPool(t *testing.T) {
+	const size = 4
+	pool := NewPool(size)
+	opt := WithWorkerPool(pool)
+	client, conn := startGreeter(t, &greeter{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The goroutines are counted from their stacks rather than by the pool,
+	// so that any a helper starts without it are caught too.
+	baseline := packageGoroutines()
+	var peak atomic.Int32
+	var mu sync.Mutex
+	unpooled := map[string]bool{}
+	sampled := make(chan struct{})
+	stop := make(chan struct{})
+
+	go func() {
+		defer close(sampled)
+
+		for {
+			pooled := int32(0)
+
+			for id, creator := range packageGoroutines() {
+				if baseline[id] != "" {
+					continue
+				} else if strings.HasPrefix(creator, "github.com/ayonli/grpc-async.(*Pool).") {
+					pooled++
+				} else {
+					mu.Lock()
+					unpooled[creator] = true
+					mu.Unlock()
+				}
+			}
+
+			if pooled > peak.Load() {
+				peak.Store(pooled)
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Microsecond):
+			}
+		}
+	}()
+
+	// Every helper taking the option runs several times at once, which is
+	// more than the pool has workers for. The pool leaves one worker besides
+	// the receive loops of the three MultiplexDuplex, which they hold until
+	// their stream ends.
+	helpers := map[string]func() error{
+		"TeeStream": func() error {
+			// The slow sink keeps the tee on its worker long enough to be
+			// seen.
+			sink := func(*examples.Response) { time.Sleep(10 * time.Millisecond) }
+
+			for range TeeStream(replyChannel(t, client, "World"), sink, opt) {
+			}
+
+			return nil
+		},
+		"Paginate": func() error {
+			items, errc := Paginate(ctx, &examples.Request{Name: "World"},
+				func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+					return client.SayHello(ctx, req)
+				},
+				func(res *examples.Response) (*examples.Request, bool) { return nil, false },
+				func(res *examples.Response) []string { return []string{res.Message} },
+				opt,
+			)
+
+			for range items {
+			}
+
+			return <-errc
+		},
+		"ResumableStream": func() error {
+			out, errc := ResumableStream[examples.Response](ctx,
+				func(string) (grpc.ClientStream, error) { return openStreamReply(ctx, conn, "World") },
+				func(res *examples.Response) string { return res.Message },
+				1,
+				opt,
+			)
+
+			for range out {
+			}
+
+			return <-errc
+		},
+		"Exchange": func() error {
+			stream, err := conn.NewStream(ctx, duplexDesc, duplexMethod)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = Exchange[examples.Request, examples.Response](ctx, stream, uploadItems("a", "b"), opt)
+			return err
+		},
+		"CloseAndRecvCtx": func() error {
+			stream, err := conn.NewStream(ctx, streamRequestDesc, streamRequestMethod)
+
+			if err != nil {
+				return err
+			} else if err := stream.SendMsg(&examples.Request{Name: "a"}); err != nil {
+				return err
+			}
+
+			_, err = CloseAndRecvCtx[examples.Response](ctx, stream, opt)
+			return err
+		},
+		"MultiplexDuplex": func() error {
+			m := newGreeterMultiplex(t, opt)
+			send, recv := m.OpenChannel("a")
+			send <- &examples.Request{Name: "World"}
+			close(send)
+
+			for range recv {
+			}
+
+			m.CloseSend()
+			return m.Err()
+		},
+	}
+
+	var wg sync.WaitGroup
+
+	for name, helper := range helpers {
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(name string, helper func() error) {
+				defer wg.Done()
+
+				if err := helper(); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}(name, helper)
+		}
+	}
+
+	wg.Wait()
+	close(stop)
+	<-sampled
+
+	if n := peak.Load(); n > size {
+		t.Errorf("%d goroutines ran on the pool at once, more than its %d workers", n, size)
+	} else if n == 0 {
+		t.Error("the helpers never used the pool")
+	}
+
+	for creator := range unpooled {
+		t.Errorf("goroutine started by %s outside the pool", creator)
+	}
+
+	// The workers of the helpers are released once they are done, which for
+	// the receive loop of MultiplexDuplex is when the stream ends.
+	for pool.Running() != 0 {
+		if ctx.Err() != nil {
+			t.Fatalf("%d workers still busy", pool.Running())
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithWorkerPoolWaits(t *testing.T) {
+	pool := NewPool(1)
+	client, conn := startGreeter(t, &greeter{}, nil)
+
+	// The tee holds the only worker until its output is drained.
+	tee := TeeStream(replyChannel(t, client, "World"), func(*examples.Response) {}, WithWorkerPool(pool))
+	stream, err := conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := CloseAndRecvCtx[examples.Response](context.Background(), stream, WithWorkerPool(pool))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("CloseAndRecvCtx returned %v without a free worker", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for range tee {
+	}
+
+	if err := <-done; status.Code(err) != codes.OK {
+		t.Errorf("err = %v once the worker is free", err)
+	}
+}
+
+func TestWithWorkerPoolCanceled(t *testing.T) {
+	pool := NewPool(1)
+	_, conn := startGreeter(t, &greeter{}, nil)
+	release := make(chan struct{})
+	defer close(release)
+
+	pool.Go(func() { <-release })
+
+	stream, err := conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := CloseAndRecvCtx[examples.Response](ctx, stream, WithWorkerPool(pool))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("err = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("CloseAndRecvCtx waited for a worker after ctx was done")
+	}
+}
+
+func TestWithWorkerPoolExchangeTooSmall(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stream, err := conn.NewStream(ctx, duplexDesc, duplexMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Exchange[examples.Request, examples.Response](ctx, stream, uploadItems("a"), WithWorkerPool(NewPool(1)))
+
+	if err == nil || ctx.Err() != nil {
+		t.Errorf("err = %v, want the pool to be rejected right away", err)
+	}
+}
+
+func TestPoolGoCtx(t *testing.T) {
+	pool := NewPool(2)
+	release := make(chan struct{})
+	pool.Go(func() { <-release })
+
+	// One worker is free, but not two at once.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	ran := make(chan struct{}, 2)
+
+	if err := pool.GoCtx(ctx, func() { ran <- struct{}{} }, func() { ran <- struct{}{} }); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	} else if len(ran) != 0 {
+		t.Error("functions ran without workers for all of them")
+	}
+
+	if err := pool.GoCtx(context.Background(), func() {}, func() {}, func() {}); err == nil {
+		t.Error("no error for more functions than workers")
+	}
+
+	close(release)
+
+	if err := pool.GoCtx(context.Background(), func() { ran <- struct{}{} }, func() { ran <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	<-ran
+	<-ran
+}
+
+// packageGoroutines returns the creators of the running goroutines started by
+// the non-test code of the package, by goroutine ID.
+func packageGoroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+
+	for {
+		if n := runtime.Stack(buf, true); n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+
+		buf = make([]byte, 2*len(buf))
+	}
+
+	creators := map[string]string{}
+
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		header, _, _ := strings.Cut(g, " [")
+		_, created, ok := strings.Cut(g, "\ncreated by ")
+
+		if !ok {
+			continue
+		}
+
+		creator, location, _ := strings.Cut(created, "\n")
+		creator, _, _ = strings.Cut(creator, " in goroutine ")
+
+		if strings.HasPrefix(creator, "github.com/ayonli/grpc-async.") && !strings.Contains(location, "_test.go") {
+			creators[strings.TrimPrefix(header, "goroutine ")] = creator
+		}
+	}
+
+	return creators
+}
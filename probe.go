@@ -0,0 +1,110 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// Capabilities reports the optional features a server was found to support.
+type Capabilities struct {
+	// Reflection is true if the server reflection service responded.
+	Reflection bool
+	// Health is true if the standard health service is registered.
+	Health bool
+	// Gzip is true if the server accepted a gzip-compressed request. It can
+	// only be detected when the health service is present.
+	Gzip bool
+}
+
+// Probe performs short, best-effort checks against conn to find out which
+// optional features the server supports, each bounded by timeout. A check
+// that fails for a reason other than the feature being absent aborts the
+// probe with its error.
+//
+// Importing this package registers the gzip compressor as a side effect.
+func Probe(ctx context.Context, conn grpc.ClientConnInterface, timeout time.Duration) (Capabilities, error) {
+	var caps Capabilities
+	var err error
+
+	if caps.Reflection, err = probeReflection(ctx, conn, timeout); err != nil {
+		return caps, err
+	}
+
+	if caps.Health, err = probeHealth(ctx, conn, timeout); err != nil {
+		return caps, err
+	}
+
+	if caps.Health {
+		if caps.Gzip, err = probeHealth(ctx, conn, timeout, grpc.UseCompressor(gzip.Name)); err != nil {
+			return caps, err
+		}
+	}
+
+	return caps, nil
+}
+
+func probeReflection(ctx context.Context, conn grpc.ClientConnInterface, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+
+	if err != nil {
+		return absent(err)
+	}
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+
+	// A stream the server already rejected fails to send with io.EOF, the
+	// actual status is reported by receiving.
+	if err == nil || err == io.EOF {
+		_, err = stream.Recv()
+	}
+
+	if err != nil {
+		return absent(err)
+	}
+
+	return true, nil
+}
+
+func probeHealth(
+	ctx context.Context,
+	conn grpc.ClientConnInterface,
+	timeout time.Duration,
+	opts ...grpc.CallOption,
+) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{}, opts...)
+
+	if status.Code(err) == codes.NotFound {
+		// The health service exists, it just doesn't know the empty service.
+		return true, nil
+	} else if err != nil {
+		return absent(err)
+	}
+
+	return true, nil
+}
+
+// absent reports an Unimplemented error as a missing feature rather than a
+// failure.
+func absent(err error) (bool, error) {
+	if status.Code(err) == codes.Unimplemented {
+		return false, nil
+	}
+
+	return false, err
+}
@@ -0,0 +1,45 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+func TestProbe(t *testing.T) {
+	full := grpc.NewServer()
+	examples.RegisterGreeterServer(full, &greeter{})
+	grpc_health_v1.RegisterHealthServer(full, health.NewServer())
+	reflection.Register(full)
+
+	bare := grpc.NewServer()
+	examples.RegisterGreeterServer(bare, &greeter{})
+
+	tests := []struct {
+		name string
+		srv  *grpc.Server
+		want Capabilities
+	}{
+		{"reflection and health", full, Capabilities{Reflection: true, Health: true, Gzip: true}},
+		{"greeter only", bare, Capabilities{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dial(t, serve(t, tt.srv))
+			caps, err := Probe(context.Background(), conn, time.Second)
+
+			if err != nil {
+				t.Fatal(err)
+			} else if caps != tt.want {
+				t.Errorf("capabilities = %+v, want %+v", caps, tt.want)
+			}
+		})
+	}
+}
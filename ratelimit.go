@@ -0,0 +1,73 @@
+package grpcasync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type keyLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// PerKeyRateLimitInterceptor returns a server interceptor that rate-limits
+// unary calls per key, e.g. an API key read from the metadata by keyFn. Each
+// key gets its own token bucket of the given limit and burst, and calls over
+// it fail with ResourceExhausted. Limiters of keys idle for idleTimeout, e.g.
+// 10 minutes, are dropped to bound memory.
+func PerKeyRateLimitInterceptor(
+	keyFn func(ctx context.Context) string,
+	limit rate.Limit,
+	burst int,
+	idleTimeout time.Duration,
+) grpc.UnaryServerInterceptor {
+	var mu sync.Mutex
+	limiters := map[string]*keyLimiter{}
+	lastSweep := time.Now()
+
+	get := func(key string) *rate.Limiter {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := time.Now()
+
+		if now.Sub(lastSweep) > idleTimeout {
+			for k, l := range limiters {
+				if now.Sub(l.lastSeen) > idleTimeout {
+					delete(limiters, k)
+				}
+			}
+
+			lastSweep = now
+		}
+
+		l, ok := limiters[key]
+
+		if !ok {
+			l = &keyLimiter{limiter: rate.NewLimiter(limit, burst)}
+			limiters[key] = l
+		}
+
+		l.lastSeen = now
+		return l.limiter
+	}
+
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if !get(keyFn(ctx)).Allow() {
+			return nil, status.Errorf(codes.ResourceExhausted, "grpcasync: rate limit exceeded for %s", info.FullMethod)
+		}
+
+		return handler(ctx, req)
+	}
+}
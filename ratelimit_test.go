@@ -0,0 +1,76 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func apiKey(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, "x-api-key"); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func callWithKey(client examples.GreeterClient, key string) error {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	_, err := client.SayHello(ctx, &examples.Request{})
+
+	return err
+}
+
+func TestPerKeyRateLimitInterceptor(t *testing.T) {
+	// Tokens are practically never refilled, only the burst is available.
+	limit := rate.Every(time.Hour)
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(PerKeyRateLimitInterceptor(apiKey, limit, 2, time.Hour)),
+	})
+
+	tests := []struct {
+		key  string
+		want codes.Code
+	}{
+		{"a", codes.OK},
+		{"a", codes.OK},
+		{"a", codes.ResourceExhausted},
+		{"b", codes.OK},
+		{"b", codes.OK},
+		{"b", codes.ResourceExhausted},
+		{"a", codes.ResourceExhausted},
+	}
+
+	for i, tt := range tests {
+		if code := status.Code(callWithKey(client, tt.key)); code != tt.want {
+			t.Errorf("call %d with key %s: code = %v, want %v", i, tt.key, code, tt.want)
+		}
+	}
+}
+
+func TestPerKeyRateLimitInterceptorEviction(t *testing.T) {
+	limit := rate.Every(time.Hour)
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(PerKeyRateLimitInterceptor(apiKey, limit, 1, 20*time.Millisecond)),
+	})
+
+	if err := callWithKey(client, "a"); err != nil {
+		t.Fatal(err)
+	} else if err := callWithKey(client, "a"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("err = %v, want ResourceExhausted", err)
+	}
+
+	// Once idle, the limiter is dropped and the key starts over.
+	time.Sleep(50 * time.Millisecond)
+
+	if err := callWithKey(client, "a"); err != nil {
+		t.Errorf("err = %v after the limiter went idle", err)
+	}
+}
@@ -0,0 +1,489 @@
+package grpcasync
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var deterministic = proto.MarshalOptions{Deterministic: true}
+
+// RecordingInterceptor returns a client interceptor that writes every unary
+// call to w, so it can later be replayed with NewReplayConn. Each call is
+// stored as four length-prefixed frames: the method, the serialized request,
+// the serialized response and the serialized status, the last two being empty
+// when absent. Write errors are ignored so that recording never fails a call.
+func RecordingInterceptor(w io.Writer) grpc.UnaryClientInterceptor {
+	var mu sync.Mutex
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		reqData, merr := marshalAny(req)
+
+		if merr != nil {
+			return err
+		}
+
+		var resData, stData []byte
+
+		if err != nil {
+			stData, _ = proto.Marshal(status.Convert(err).Proto())
+		} else {
+			resData, _ = marshalAny(reply)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		writeFrames(w, []byte(method), reqData, resData, stData)
+
+		return err
+	}
+}
+
+// ReplayConn is a grpc.ClientConnInterface that answers unary calls with the
+// traffic captured by RecordingInterceptor, and server-streaming calls with
+// the traffic captured by RecordingStreamInterceptor and loaded with
+// AddStreams, without any server. A call is matched by its method and
+// serialized request; repeated identical calls are answered in recorded
+// order, reusing the last answer once exhausted.
+type ReplayConn struct {
+	mu      sync.Mutex
+	records map[string][]replayRecord
+	streams map[string][]*streamRecord
+}
+
+type replayRecord struct {
+	res []byte
+	st  []byte
+}
+
+// NewReplayConn reads all recorded calls from r.
+func NewReplayConn(r io.Reader) (*ReplayConn, error) {
+	conn := &ReplayConn{
+		records: map[string][]replayRecord{},
+		streams: map[string][]*streamRecord{},
+	}
+
+	for {
+		frames, err := readFrames(r, 4)
+
+		if err == io.EOF {
+			return conn, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("grpcasync: invalid recording: %w", err)
+		}
+
+		key := string(frames[0]) + "\x00" + string(frames[1])
+		conn.records[key] = append(conn.records[key], replayRecord{frames[2], frames[3]})
+	}
+}
+
+// Invoke implements grpc.ClientConnInterface.
+func (c *ReplayConn) Invoke(ctx context.Context, method string, args, reply any, _ ...grpc.CallOption) error {
+	data, err := marshalAny(args)
+
+	if err != nil {
+		return err
+	}
+
+	key := method + "\x00" + string(data)
+
+	c.mu.Lock()
+	records := c.records[key]
+
+	if len(records) == 0 {
+		c.mu.Unlock()
+		return status.Errorf(codes.NotFound, "grpcasync: no recorded call to %s matches the request", method)
+	}
+
+	record := records[0]
+
+	if len(records) > 1 {
+		c.records[key] = records[1:]
+	}
+
+	c.mu.Unlock()
+
+	if len(record.st) > 0 {
+		st := new(spb.Status)
+
+		if err := proto.Unmarshal(record.st, st); err != nil {
+			return err
+		}
+
+		return status.ErrorProto(st)
+	}
+
+	msg, ok := reply.(proto.Message)
+
+	if !ok {
+		return fmt.Errorf("grpcasync: %T is not a proto message", reply)
+	}
+
+	return proto.Unmarshal(record.res, msg)
+}
+
+// NewStream implements grpc.ClientConnInterface, only server streams are
+// supported.
+func (c *ReplayConn) NewStream(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	method string,
+	_ ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	if desc.ClientStreams {
+		return nil, status.Errorf(codes.Unimplemented, "grpcasync: cannot replay client stream %s", method)
+	}
+
+	return &ReplayStream{conn: c, ctx: ctx, method: method}, nil
+}
+
+// RecordingStreamInterceptor is the counterpart of RecordingInterceptor for
+// server streams, it writes every server-streaming call to w once the stream
+// has ended, so it can be replayed with ReplayConn.AddStreams. Each call is
+// stored as the method, the serialized request, the number of messages, a
+// pair of frames per message holding its delay after the previous one and its
+// serialized form, and the serialized status. Streams the caller stops
+// reading before the end are not recorded, and client and bidirectional
+// streams are passed through. Wrap w in a gzip.Writer to compress the
+// recording.
+func RecordingStreamInterceptor(w io.Writer) grpc.StreamClientInterceptor {
+	var mu sync.Mutex
+
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+
+		if err != nil || desc.ClientStreams {
+			return stream, err
+		}
+
+		return &recordingStream{
+			ClientStream: stream,
+			mu:           &mu,
+			w:            w,
+			method:       method,
+			last:         time.Now(),
+		}, nil
+	}
+}
+
+type recordingStream struct {
+	grpc.ClientStream
+	mu     *sync.Mutex
+	w      io.Writer
+	method string
+	req    []byte
+	frames [][]byte
+	last   time.Time
+	done   bool
+}
+
+func (s *recordingStream) SendMsg(m any) error {
+	if s.req == nil {
+		s.req, _ = marshalAny(m)
+	}
+
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *recordingStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+
+	if s.done || s.req == nil {
+		return err
+	} else if err == nil {
+		now := time.Now()
+		data, merr := marshalAny(m)
+
+		if merr != nil {
+			// Unrecordable streams are dropped.
+			s.done = true
+			return err
+		}
+
+		var delay [8]byte
+		binary.BigEndian.PutUint64(delay[:], uint64(now.Sub(s.last)))
+		s.frames = append(s.frames, delay[:], data)
+		s.last = now
+
+		return nil
+	}
+
+	s.done = true
+	var stData []byte
+
+	if err != io.EOF {
+		stData, _ = proto.Marshal(status.Convert(err).Proto())
+	}
+
+	var count [4]byte
+	binary.BigEndian.PutUint32(count[:], uint32(len(s.frames)/2))
+
+	frames := append([][]byte{[]byte(s.method), s.req, count[:]}, s.frames...)
+	frames = append(frames, stData)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeFrames(s.w, frames...)
+
+	return err
+}
+
+type streamRecord struct {
+	delays []time.Duration
+	msgs   [][]byte
+	st     []byte
+}
+
+// AddStreams reads all server streams recorded by RecordingStreamInterceptor
+// from r, for NewStream to replay. Wrap r in a gzip.Reader if the recording
+// is compressed.
+func (c *ReplayConn) AddStreams(r io.Reader) error {
+	for {
+		frames, err := readFrames(r, 3)
+
+		if err == io.EOF {
+			return nil
+		} else if err == nil && len(frames[2]) != 4 {
+			err = errors.New("malformed message count")
+		}
+
+		var body [][]byte
+
+		if err == nil {
+			n := int(binary.BigEndian.Uint32(frames[2]))
+			body, err = readFrames(r, 2*n+1)
+
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("grpcasync: invalid stream recording: %w", err)
+		}
+
+		record := &streamRecord{st: body[len(body)-1]}
+
+		for i := 0; i+1 < len(body); i += 2 {
+			if len(body[i]) != 8 {
+				return errors.New("grpcasync: invalid stream recording: malformed delay")
+			}
+
+			record.delays = append(record.delays, time.Duration(binary.BigEndian.Uint64(body[i])))
+			record.msgs = append(record.msgs, body[i+1])
+		}
+
+		key := string(frames[0]) + "\x00" + string(frames[1])
+
+		c.mu.Lock()
+		c.streams[key] = append(c.streams[key], record)
+		c.mu.Unlock()
+	}
+}
+
+// ReplayStream is a server stream replayed by ReplayConn. Its messages are
+// received with their original timing relative to each other, and the stream
+// ends with the recorded status. Waiting for a message is interrupted when
+// the context of the stream is done.
+type ReplayStream struct {
+	conn   *ReplayConn
+	ctx    context.Context
+	method string
+	req    []byte
+	record *streamRecord
+	next   int
+}
+
+// Header implements grpc.ClientStream, headers are not recorded.
+func (s *ReplayStream) Header() (metadata.MD, error) {
+	return metadata.MD{}, nil
+}
+
+// Trailer implements grpc.ClientStream, trailers are not recorded.
+func (s *ReplayStream) Trailer() metadata.MD {
+	return metadata.MD{}
+}
+
+// CloseSend implements grpc.ClientStream.
+func (s *ReplayStream) CloseSend() error {
+	return nil
+}
+
+// Context implements grpc.ClientStream.
+func (s *ReplayStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg records the request the stream is matched by, only one is
+// accepted.
+func (s *ReplayStream) SendMsg(m any) error {
+	if s.req != nil {
+		return status.Errorf(codes.Unimplemented, "grpcasync: cannot replay client stream %s", s.method)
+	}
+
+	data, err := marshalAny(m)
+
+	if err != nil {
+		return err
+	}
+
+	s.req = data
+	return nil
+}
+
+// RecvMsg receives the next recorded message, io.EOF once the recorded
+// stream has ended successfully, or its recorded error.
+func (s *ReplayStream) RecvMsg(m any) error {
+	if s.record == nil {
+		record, err := s.conn.takeStream(s.method, s.req)
+
+		if err != nil {
+			return err
+		}
+
+		s.record = record
+	}
+
+	if s.next < len(s.record.msgs) {
+		if delay := s.record.delays[s.next]; delay > 0 {
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-s.ctx.Done():
+				return status.FromContextError(s.ctx.Err()).Err()
+			}
+		}
+
+		msg, ok := m.(proto.Message)
+
+		if !ok {
+			return fmt.Errorf("grpcasync: %T is not a proto message", m)
+		}
+
+		data := s.record.msgs[s.next]
+		s.next++
+
+		return proto.Unmarshal(data, msg)
+	}
+
+	if len(s.record.st) > 0 {
+		st := new(spb.Status)
+
+		if err := proto.Unmarshal(s.record.st, st); err != nil {
+			return err
+		}
+
+		return status.ErrorProto(st)
+	}
+
+	return io.EOF
+}
+
+func (c *ReplayConn) takeStream(method string, req []byte) (*streamRecord, error) {
+	key := method + "\x00" + string(req)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records := c.streams[key]
+
+	if len(records) == 0 {
+		return nil, status.Errorf(codes.NotFound, "grpcasync: no recorded stream of %s matches the request", method)
+	}
+
+	if len(records) > 1 {
+		c.streams[key] = records[1:]
+	}
+
+	return records[0], nil
+}
+
+func marshalAny(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+
+	if !ok {
+		return nil, fmt.Errorf("grpcasync: %T is not a proto message", v)
+	}
+
+	return deterministic.Marshal(msg)
+}
+
+func writeFrames(w io.Writer, frames ...[]byte) error {
+	for _, frame := range frames {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(frame)))
+
+		if _, err := w.Write(size[:]); err != nil {
+			return err
+		} else if _, err := w.Write(frame); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readFrames reads n frames written by writeFrames, it returns io.EOF only if
+// r ends before the first one. The frames are read as they come rather than
+// allocated by the sizes in r, so that a corrupt count or size fails once r
+// runs out instead of exhausting memory.
+func readFrames(r io.Reader, n int) ([][]byte, error) {
+	frames := make([][]byte, 0, min(n, 64))
+
+	for i := 0; i < n; i++ {
+		var size [4]byte
+
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			if i > 0 && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		length := int64(binary.BigEndian.Uint32(size[:]))
+		frame, err := io.ReadAll(io.LimitReader(r, length))
+
+		if err == nil && int64(len(frame)) < length {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		frames = append(frames, frame)
+	}
+
+	return frames, nil
+}
@@ -0,0 +1,202 @@
+package grpcasync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	var recording bytes.Buffer
+	impl := &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		if req.Name == "" {
+			return nil, status.Error(codes.InvalidArgument, "empty name")
+		}
+
+		return &examples.Response{Message: "Hello, " + req.Name}, nil
+	}}
+
+	client, conn := startGreeter(t, impl, nil, grpc.WithUnaryInterceptor(RecordingInterceptor(&recording)))
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	} else if _, err := client.SayHello(context.Background(), &examples.Request{}); err == nil {
+		t.Fatal("no error for an empty name")
+	}
+
+	// Replay without any server.
+	conn.Close()
+	replay, err := NewReplayConn(&recording)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client = examples.NewGreeterClient(replay)
+	res, err := client.SayHello(context.Background(), &examples.Request{Name: "World"})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, World" {
+		t.Errorf("replayed message = %q", res.Message)
+	}
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("replayed err = %v, want InvalidArgument", err)
+	}
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{Name: "Alice"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unrecorded call err = %v, want NotFound", err)
+	}
+}
+
+// pacedGreeter streams three replies on SayHelloStreamReply, gap apart, and
+// fails the stream after them if the request is named "fail".
+type pacedGreeter struct {
+	greeter
+	gap time.Duration
+}
+
+func (g *pacedGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	for i, n := range []string{"1", "2", "3"} {
+		if i > 0 {
+			time.Sleep(g.gap)
+		}
+
+		if err := stream.Send(&examples.Response{Message: "Hello " + n + ": " + req.Name}); err != nil {
+			return err
+		}
+	}
+
+	if req.Name == "fail" {
+		return status.Error(codes.Internal, "broken")
+	}
+
+	return nil
+}
+
+func TestRecordAndReplayStream(t *testing.T) {
+	var recording bytes.Buffer
+	zw := gzip.NewWriter(&recording)
+	_, conn := startGreeter(t, &pacedGreeter{gap: 30 * time.Millisecond}, nil,
+		grpc.WithStreamInterceptor(RecordingStreamInterceptor(zw)))
+
+	for _, name := range []string{"World", "fail"} {
+		stream, err := openStreamReply(context.Background(), conn, name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for {
+			if err := stream.RecvMsg(new(examples.Response)); err != nil {
+				break
+			}
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay the compressed recording without any server.
+	conn.Close()
+	replay, err := NewReplayConn(bytes.NewReader(nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := gzip.NewReader(&recording)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err := replay.AddStreams(zr); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		code codes.Code
+	}{
+		{"World", codes.OK},
+		{"fail", codes.Internal},
+	}
+
+	for _, tt := range tests {
+		stream, err := openStreamReply(context.Background(), replay, tt.name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var msgs []string
+		var gaps []time.Duration
+		last := time.Now()
+
+		for {
+			res := new(examples.Response)
+
+			if err = stream.RecvMsg(res); err != nil {
+				break
+			}
+
+			msgs = append(msgs, res.Message)
+			gaps = append(gaps, time.Since(last))
+			last = time.Now()
+		}
+
+		if err == io.EOF {
+			err = nil
+		}
+
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.code)
+		}
+
+		want := []string{"Hello 1: " + tt.name, "Hello 2: " + tt.name, "Hello 3: " + tt.name}
+
+		if !reflect.DeepEqual(msgs, want) {
+			t.Fatalf("%s: replayed %q, want %q", tt.name, msgs, want)
+		}
+
+		// The messages keep their original spacing.
+		for i, gap := range gaps[1:] {
+			if gap < 25*time.Millisecond {
+				t.Errorf("%s: message %d replayed %v after the previous one, want about 30ms", tt.name, i+2, gap)
+			}
+		}
+	}
+}
+
+func TestReplayCorruptRecording(t *testing.T) {
+	// Counts and sizes far beyond the data that follows must fail once the
+	// input runs out.
+	hugeCount := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 4, 0xff, 0xff, 0xff, 0xff}
+	hugeFrame := []byte{0xff, 0xff, 0xff, 0xff, 'x'}
+
+	conn, err := NewReplayConn(bytes.NewReader(nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, input := range map[string][]byte{"count": hugeCount, "frame": hugeFrame} {
+		if err := conn.AddStreams(bytes.NewReader(input)); err == nil {
+			t.Errorf("AddStreams: no error for a huge %s", name)
+		}
+	}
+
+	if _, err := NewReplayConn(bytes.NewReader(hugeFrame)); err == nil {
+		t.Error("NewReplayConn: no error for a huge frame")
+	}
+}
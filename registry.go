@@ -0,0 +1,61 @@
+package grpcasync
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type methodTypes struct {
+	req  protoreflect.MessageType
+	resp protoreflect.MessageType
+}
+
+var methodRegistry sync.Map // map[string]methodTypes
+
+// RegisterMethod records the request and response message types of a method,
+// e.g. "/examples.Greeter/SayHello", so that generic tooling can construct
+// them by name via NewRequest and NewResponse. Only the types of the given
+// messages are kept, their contents are ignored.
+func RegisterMethod(fullMethod string, reqType, respType proto.Message) {
+	methodRegistry.Store(fullMethod, methodTypes{
+		req:  reqType.ProtoReflect().Type(),
+		resp: respType.ProtoReflect().Type(),
+	})
+}
+
+// NewRequest returns a new, empty instance of the request type registered for
+// fullMethod.
+func NewRequest(fullMethod string) (proto.Message, error) {
+	types, err := lookupMethod(fullMethod)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return types.req.New().Interface(), nil
+}
+
+// NewResponse returns a new, empty instance of the response type registered
+// for fullMethod.
+func NewResponse(fullMethod string) (proto.Message, error) {
+	types, err := lookupMethod(fullMethod)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return types.resp.New().Interface(), nil
+}
+
+func lookupMethod(fullMethod string) (methodTypes, error) {
+	value, ok := methodRegistry.Load(fullMethod)
+
+	if !ok {
+		return methodTypes{}, fmt.Errorf("grpcasync: method %s is not registered", fullMethod)
+	}
+
+	return value.(methodTypes), nil
+}
@@ -0,0 +1,46 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMethodRegistry(t *testing.T) {
+	RegisterMethod(sayHelloMethod, &examples.Request{Name: "ignored"}, &examples.Response{})
+
+	req, err := NewRequest(sayHelloMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if r, ok := req.(*examples.Request); !ok {
+		t.Fatalf("request is a %T, want *examples.Request", req)
+	} else if !proto.Equal(r, &examples.Request{}) {
+		t.Errorf("request = %v, want an empty one", r)
+	}
+
+	res, err := NewResponse(sayHelloMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if _, ok := res.(*examples.Response); !ok {
+		t.Fatalf("response is a %T, want *examples.Response", res)
+	}
+
+	// The request is usable with the Greeter.
+	req.(*examples.Request).Name = "World"
+	client, _ := startGreeter(t, &greeter{}, nil)
+	got, err := client.SayHello(context.Background(), req.(*examples.Request))
+
+	if err != nil || got.Message != "Hello, World" {
+		t.Errorf("SayHello = %v, %v", got, err)
+	}
+}
+
+func TestMethodRegistryUnknown(t *testing.T) {
+	if _, err := NewRequest("/examples.Greeter/Unknown"); err == nil {
+		t.Error("NewRequest succeeded for an unregistered method")
+	}
+}
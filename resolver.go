@@ -0,0 +1,113 @@
+package grpcasync
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// RegisterResolver registers a resolver for the given scheme that obtains the
+// backend addresses by calling build with the target endpoint, e.g. "service"
+// for "myscheme:///service". The addresses are refreshed every interval and
+// whenever gRPC asks for re-resolution, or only then if interval is not
+// positive.
+//
+// Like resolver.Register, this function is not thread-safe and should only be
+// called during initialization. Combine it with a round_robin service config
+// to spread calls across the returned addresses.
+func RegisterResolver(scheme string, interval time.Duration, build func(target string) ([]string, error)) {
+	resolver.Register(&funcResolverBuilder{scheme: scheme, interval: interval, build: build})
+}
+
+type funcResolverBuilder struct {
+	scheme   string
+	interval time.Duration
+	build    func(target string) ([]string, error)
+}
+
+func (b *funcResolverBuilder) Scheme() string {
+	return b.scheme
+}
+
+func (b *funcResolverBuilder) Build(
+	target resolver.Target,
+	cc resolver.ClientConn,
+	_ resolver.BuildOptions,
+) (resolver.Resolver, error) {
+	r := &funcResolver{
+		target: target.Endpoint(),
+		build:  b.build,
+		cc:     cc,
+		now:    make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	r.resolve()
+	r.wg.Add(1)
+	go r.watch(b.interval)
+
+	return r, nil
+}
+
+type funcResolver struct {
+	target string
+	build  func(target string) ([]string, error)
+	cc     resolver.ClientConn
+	now    chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func (r *funcResolver) resolve() {
+	addrs, err := r.build(r.target)
+
+	if err != nil {
+		r.cc.ReportError(err)
+		return
+	}
+
+	state := resolver.State{Addresses: make([]resolver.Address, len(addrs))}
+
+	for i, addr := range addrs {
+		state.Addresses[i] = resolver.Address{Addr: addr}
+	}
+
+	r.cc.UpdateState(state)
+}
+
+func (r *funcResolver) watch(interval time.Duration) {
+	defer r.wg.Done()
+
+	// A nil channel never fires, leaving only the re-resolutions asked for.
+	var tick <-chan time.Time
+
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-tick:
+		case <-r.now:
+		}
+
+		r.resolve()
+	}
+}
+
+func (r *funcResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.now <- struct{}{}:
+	default:
+	}
+}
+
+func (r *funcResolver) Close() {
+	close(r.done)
+	r.wg.Wait()
+}
@@ -0,0 +1,92 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestRegisterResolver(t *testing.T) {
+	dialer := serveBackends(t, "a", "b")
+
+	var targets []string
+	RegisterResolver("fnresolver", time.Hour, func(target string) ([]string, error) {
+		targets = append(targets, target)
+		return []string{"a", "b"}, nil
+	})
+
+	conn, err := grpc.Dial("fnresolver:///greeter",
+		dialer,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig":[{"round_robin":{}}]}`),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	client := examples.NewGreeterClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	call := func() string {
+		res, err := client.SayHello(ctx, &examples.Request{}, grpc.WaitForReady(true))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return res.Message
+	}
+
+	// Round-robin only includes ready backends, wait for both.
+	for seen := map[string]bool{}; len(seen) < 2; {
+		seen[call()] = true
+	}
+
+	counts := map[string]int{}
+
+	for i := 0; i < 10; i++ {
+		counts[call()]++
+	}
+
+	if counts["a"] != 5 || counts["b"] != 5 {
+		t.Errorf("calls per backend = %v, want 5 each", counts)
+	}
+
+	if len(targets) == 0 || targets[0] != "greeter" {
+		t.Errorf("build called with %q, want greeter", targets)
+	}
+}
+
+func TestRegisterResolverNoInterval(t *testing.T) {
+	RegisterResolver("fnresolvernointerval", 0, func(target string) ([]string, error) {
+		return []string{"a"}, nil
+	})
+
+	conn, err := grpc.Dial("fnresolvernointerval:///greeter",
+		serveBackends(t, "a"),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := examples.NewGreeterClient(conn).SayHello(ctx, &examples.Request{}, grpc.WaitForReady(true))
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Message != "a" {
+		t.Errorf("call went to %q, want a", res.Message)
+	}
+}
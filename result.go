@@ -0,0 +1,71 @@
+package grpcasync
+
+import (
+	"fmt"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Result is a single item of a stream that may carry per-item errors, as
+// produced by SendResult and reconstructed by RecvResult.
+type Result[Res any] struct {
+	Value *Res
+	Err   error
+}
+
+// SendResult sends either res or err as the next item of a server stream
+// without ending the stream, so that one failed item doesn't abort the rest.
+// Items are wrapped in google.protobuf.Any, holding the response message or a
+// google.rpc.Status respectively, so the method must be declared to stream
+// google.protobuf.Any in the .proto file.
+func SendResult(stream grpc.ServerStream, res proto.Message, err error) error {
+	var item *anypb.Any
+
+	if err != nil {
+		item, err = anypb.New(status.Convert(err).Proto())
+	} else {
+		item, err = anypb.New(res)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return stream.SendMsg(item)
+}
+
+// RecvResult receives the next item sent by SendResult. The returned error is
+// that of the stream itself, e.g. io.EOF once it ends, while an error sent for
+// the item is reported in Result.Err.
+func RecvResult[Res any](stream grpc.ClientStream) (Result[Res], error) {
+	item := new(anypb.Any)
+
+	if err := stream.RecvMsg(item); err != nil {
+		return Result[Res]{}, err
+	}
+
+	st := new(spb.Status)
+
+	if item.MessageIs(st) {
+		if err := item.UnmarshalTo(st); err != nil {
+			return Result[Res]{}, err
+		}
+
+		return Result[Res]{Err: status.ErrorProto(st)}, nil
+	}
+
+	res := new(Res)
+	msg, ok := any(res).(proto.Message)
+
+	if !ok {
+		return Result[Res]{}, fmt.Errorf("grpcasync: %T is not a proto message", res)
+	} else if err := item.UnmarshalTo(msg); err != nil {
+		return Result[Res]{}, err
+	}
+
+	return Result[Res]{Value: res}, nil
+}
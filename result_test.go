@@ -0,0 +1,77 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resultGreeterDesc declares SayHelloStreamReply as streaming
+// google.protobuf.Any, each reply being a Result: names that are empty fail
+// with InvalidArgument.
+var resultGreeterDesc = grpc.ServiceDesc{
+	ServiceName: "examples.Greeter",
+	HandlerType: (*any)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "SayHelloStreamReply",
+		ServerStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			for _, name := range []string{"Alice", "", "Bob"} {
+				var err error
+
+				if name == "" {
+					err = status.Error(codes.InvalidArgument, "empty name")
+				}
+
+				if err := SendResult(stream, &examples.Response{Message: "Hello, " + name}, err); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}},
+}
+
+func TestSendResult(t *testing.T) {
+	srv := grpc.NewServer()
+	srv.RegisterService(&resultGreeterDesc, struct{}{})
+	conn := dial(t, serve(t, srv))
+	stream, err := openStreamReply(context.Background(), conn, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		message string
+		code    codes.Code
+	}{
+		{"Hello, Alice", codes.OK},
+		{"", codes.InvalidArgument},
+		{"Hello, Bob", codes.OK},
+	}
+
+	for i, w := range want {
+		res, err := RecvResult[examples.Response](stream)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code := status.Code(res.Err); code != w.code {
+			t.Errorf("item %d: code = %v, want %v", i, code, w.code)
+		} else if res.Err == nil && res.Value.Message != w.message {
+			t.Errorf("item %d: message = %q, want %q", i, res.Value.Message, w.message)
+		}
+	}
+
+	if _, err := RecvResult[examples.Response](stream); err != io.EOF {
+		t.Errorf("err = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,32 @@
+package grpcasync
+
+import "context"
+
+// RunCancellable runs fn, which doesn't take a context, in a goroutine and
+// returns its results, or ctx.Err() as soon as ctx is done, so that handlers
+// honor deadlines around blocking library calls.
+//
+// fn cannot be stopped from the outside: when ctx wins, the goroutine keeps
+// running until fn returns and its results are discarded. fn must therefore
+// eventually return and must not rely on the handler still being active.
+func RunCancellable[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
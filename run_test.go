@@ -0,0 +1,51 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRunCancellable(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	// The blocking call named "block" only returns once the test ends.
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		msg, err := RunCancellable(ctx, func() (string, error) {
+			if req.Name == "block" {
+				<-release
+			}
+
+			return "Hello, " + req.Name, nil
+		})
+
+		if err != nil {
+			return nil, status.FromContextError(err).Err()
+		}
+
+		return &examples.Response{Message: msg}, nil
+	}}, nil)
+
+	if res, err := client.SayHello(context.Background(), &examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, World" {
+		t.Errorf("message = %q", res.Message)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.SayHello(ctx, &examples.Request{Name: "block"})
+
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("call returned after %v, long after its deadline", elapsed)
+	}
+}
@@ -0,0 +1,78 @@
+//go:build go1.23
+
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"iter"
+
+	"google.golang.org/grpc"
+)
+
+// StreamSeq returns an iterator over the messages of a server stream, for use
+// with range-over-func:
+//
+//	for res, err := range grpcasync.StreamSeq[examples.Response](ctx, stream) {
+//		if err != nil {
+//			return err
+//		}
+//		// ...
+//	}
+//
+// The iteration ends after the last message, or after yielding the first
+// error together with a nil message. ctx is checked before every receive;
+// opening the stream with ctx (or a context derived from it) also interrupts
+// a pending one. Breaking out of the loop leaves the stream open, cancel its
+// context to end it.
+func StreamSeq[Res any](ctx context.Context, stream grpc.ClientStream) iter.Seq2[*Res, error] {
+	return func(yield func(*Res, error) bool) {
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			res := new(Res)
+
+			if err := stream.RecvMsg(res); err == io.EOF {
+				return
+			} else if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !yield(res, nil) {
+				return
+			}
+		}
+	}
+}
+
+// SendSeq sends every message yielded by seq over a client stream, then
+// half-closes it and returns the response, the counterpart of StreamSeq for
+// client streams. The iteration stops at the first failed send. A stream
+// aborted by the server reports its status rather than io.EOF.
+func SendSeq[Req any, Res any](stream grpc.ClientStream, seq iter.Seq[*Req]) (*Res, error) {
+	for req := range seq {
+		if err := stream.SendMsg(req); err == io.EOF {
+			// The stream was aborted, the actual status is reported by
+			// receiving.
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	res := new(Res)
+
+	if err := stream.RecvMsg(res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
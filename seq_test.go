@@ -0,0 +1,153 @@
+//go:build go1.23
+
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStreamSeq(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	stream, err := openStreamReply(context.Background(), conn, "World")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+
+	for res, err := range StreamSeq[examples.Response](context.Background(), stream) {
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, res.Message)
+	}
+
+	if want := []string{"Hello 1: World", "Hello 2: World", "Hello 3: World"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+// brokenStreamGreeter fails SayHelloStreamReply after its first reply.
+type brokenStreamGreeter struct {
+	greeter
+}
+
+func (g *brokenStreamGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	if err := stream.Send(&examples.Response{Message: "first"}); err != nil {
+		return err
+	}
+
+	return status.Error(codes.Internal, "broken")
+}
+
+func TestStreamSeqError(t *testing.T) {
+	_, conn := startGreeter(t, &brokenStreamGreeter{}, nil)
+	stream, err := openStreamReply(context.Background(), conn, "World")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs, errs int
+
+	for res, err := range StreamSeq[examples.Response](context.Background(), stream) {
+		if err != nil {
+			errs++
+
+			if status.Code(err) != codes.Internal || res != nil {
+				t.Errorf("yielded %v, %v, want a nil message with the Internal error", res, err)
+			}
+		} else {
+			msgs++
+		}
+	}
+
+	if msgs != 1 || errs != 1 {
+		t.Errorf("yielded %d messages and %d errors, want 1 of each", msgs, errs)
+	}
+}
+
+func TestStreamSeqCanceled(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := openStreamReply(ctx, conn, "World")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs int
+
+	for _, err := range StreamSeq[examples.Response](ctx, stream) {
+		if err != nil {
+			if !errors.Is(err, context.Canceled) && status.Code(err) != codes.Canceled {
+				t.Errorf("err = %v, want the cancellation", err)
+			}
+
+			continue
+		}
+
+		if msgs++; msgs == 1 {
+			cancel()
+		}
+	}
+
+	if msgs != 1 {
+		t.Errorf("yielded %d messages, want 1 before the cancellation", msgs)
+	}
+}
+
+func TestSendSeq(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	stream, err := conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(yield func(*examples.Request) bool) {
+		for _, name := range []string{"Alice", "Bob", "Carol"} {
+			if !yield(&examples.Request{Name: name}) {
+				return
+			}
+		}
+	}
+
+	res, err := SendSeq[examples.Request, examples.Response](stream, names)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, Alice, Bob, Carol" {
+		t.Errorf("response = %q", res.Message)
+	}
+}
+
+func TestSendSeqAborted(t *testing.T) {
+	_, conn := startGreeter(t, &rejectingUploadGreeter{canceled: make(chan struct{})}, nil)
+	stream, err := conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(yield func(*examples.Request) bool) {
+		for yield(&examples.Request{Name: "bad"}) {
+		}
+	}
+
+	// The sequence never ends, only the abort of the server stops it.
+	if _, err := SendSeq[examples.Request, examples.Response](stream, names); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,43 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// StopAll gracefully stops all servers concurrently. Servers still draining
+// when ctx is done are stopped forcefully, closing their pending calls, and
+// are reported in the returned error, which wraps ctx.Err() for each of them.
+func StopAll(ctx context.Context, servers ...*grpc.Server) error {
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+
+	for i, srv := range servers {
+		wg.Add(1)
+
+		go func(i int, srv *grpc.Server) {
+			defer wg.Done()
+			done := make(chan struct{})
+
+			go func() {
+				srv.GracefulStop()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-ctx.Done():
+				srv.Stop()
+				<-done
+				errs[i] = fmt.Errorf("grpcasync: server %d stopped forcefully: %w", i, ctx.Err())
+			}
+		}(i, srv)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
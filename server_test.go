@@ -0,0 +1,78 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStopAll(t *testing.T) {
+	idle := grpc.NewServer()
+	examples.RegisterGreeterServer(idle, &greeter{})
+	dial(t, serve(t, idle))
+
+	entered := make(chan struct{})
+	hung := grpc.NewServer(grpc.StreamInterceptor(func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		close(entered)
+		return handler(srv, stream)
+	}))
+	examples.RegisterGreeterServer(hung, &stallingGreeter{})
+	conn := dial(t, serve(t, hung))
+
+	stream, err := conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err := stream.SendMsg(&examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stream must have reached the handler to keep the server draining.
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = StopAll(ctx, idle, hung)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	} else if msg := err.Error(); !strings.Contains(msg, "server 1") || strings.Contains(msg, "server 0") {
+		t.Errorf("err = %v, want only the hung server reported", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("StopAll returned after %v, long after the deadline", elapsed)
+	}
+
+	if err := stream.RecvMsg(new(examples.Response)); status.Code(err) != codes.Unavailable {
+		t.Errorf("hung stream err = %v, want Unavailable", err)
+	}
+}
+
+func TestStopAllGraceful(t *testing.T) {
+	srv := grpc.NewServer()
+	examples.RegisterGreeterServer(srv, &greeter{})
+	dial(t, serve(t, srv))
+
+	if err := StopAll(context.Background(), srv); err != nil {
+		t.Error(err)
+	}
+}
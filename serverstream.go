@@ -0,0 +1,287 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// SendFromWithHeartbeat sends every message received from ch over stream until
+// ch is closed. Whenever no message arrives for heartbeat, onTimeout is
+// called: if it returns true, the message it returns is sent as a heartbeat
+// and waiting resumes, otherwise the stream is aborted with DeadlineExceeded.
+func SendFromWithHeartbeat[Res any](
+	stream grpc.ServerStream,
+	ch <-chan *Res,
+	heartbeat time.Duration,
+	onTimeout func() (*Res, bool),
+) error {
+	timer := time.NewTimer(heartbeat)
+	defer timer.Stop()
+
+	for {
+		var msg *Res
+
+		select {
+		case res, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			msg = res
+
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-timer.C:
+			res, ok := onTimeout()
+
+			if !ok {
+				return status.Errorf(codes.DeadlineExceeded,
+					"grpcasync: no message produced within %v", heartbeat)
+			}
+
+			msg = res
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+
+		timer.Reset(heartbeat)
+	}
+}
+
+// Broadcast sends msg to all streams while marshaling it only once, using
+// grpc.PreparedMsg. The message is encoded for the first stream, so all
+// streams must share the same codec and compressor, which is the case for
+// streams of the same method unless clients negotiate compression
+// differently. Errors of individual streams are joined, a failed stream does
+// not keep the others from receiving the message.
+func Broadcast(streams []grpc.ServerStream, msg proto.Message) error {
+	if len(streams) == 0 {
+		return nil
+	}
+
+	prepared := new(grpc.PreparedMsg)
+
+	if err := prepared.Encode(streams[0], msg); err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, stream := range streams {
+		if err := stream.SendMsg(prepared); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ErrStreamClosed is returned by SafeServerStream.SendMsg once the stream has
+// ended.
+var ErrStreamClosed = errors.New("grpcasync: send on closed stream")
+
+// SafeServerStream wraps a server stream for handlers that send from several
+// goroutines. Sends are serialized, and sending after the handler has
+// returned or the call has ended yields ErrStreamClosed instead of reaching
+// a stream that is no longer valid:
+//
+//	safe := grpcasync.NewSafeServerStream(stream)
+//	defer safe.Close()
+type SafeServerStream struct {
+	grpc.ServerStream
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewSafeServerStream wraps stream, Close must be called before the handler
+// returns.
+func NewSafeServerStream(stream grpc.ServerStream) *SafeServerStream {
+	return &SafeServerStream{ServerStream: stream}
+}
+
+// SendMsg sends m unless the stream has ended.
+func (s *SafeServerStream) SendMsg(m any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.Context().Err() != nil {
+		return ErrStreamClosed
+	}
+
+	return s.ServerStream.SendMsg(m)
+}
+
+// Close marks the stream as ended, waiting for a send in progress to finish.
+func (s *SafeServerStream) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
+
+// DebouncedSend sends the messages received from ch over stream, merging those
+// that arrive within window of the first pending one into a single message,
+// which reduces frames under bursts. Pending messages are flushed as soon as
+// ch is closed, after which DebouncedSend returns.
+func DebouncedSend[Res any](
+	stream grpc.ServerStream,
+	ch <-chan *Res,
+	window time.Duration,
+	merge func(batch []*Res) *Res,
+) error {
+	var pending []*Res
+	var flush <-chan time.Time
+
+	send := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+
+		err := stream.SendMsg(merge(pending))
+		pending, flush = nil, nil
+
+		return err
+	}
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return send()
+			}
+
+			if len(pending) == 0 {
+				flush = time.After(window)
+			}
+
+			pending = append(pending, msg)
+		case <-flush:
+			if err := send(); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+var recvLimiters sync.Map // map[grpc.ServerStream]*rate.Limiter
+
+// RecvRateLimit receives the next message of stream, but no faster than limit
+// messages per second over the life of the stream. While it waits, unread
+// messages pile up in the transport until HTTP/2 flow control pushes back on
+// the client, so a flooding client is slowed down rather than buffered
+// without bound. The limit of the first call for a stream applies to all
+// further calls for it.
+func RecvRateLimit[Req any](stream grpc.ServerStream, limit rate.Limit) (*Req, error) {
+	value, loaded := recvLimiters.LoadOrStore(stream, rate.NewLimiter(limit, 1))
+
+	if !loaded {
+		context.AfterFunc(stream.Context(), func() {
+			recvLimiters.Delete(stream)
+		})
+	}
+
+	if err := value.(*rate.Limiter).Wait(stream.Context()); err != nil {
+		if cause := stream.Context().Err(); cause != nil {
+			return nil, status.FromContextError(cause).Err()
+		}
+
+		// The wait would outlast the deadline of the call.
+		return nil, status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	req := new(Req)
+
+	if err := stream.RecvMsg(req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// ByteBudgetServerStream wraps a server stream to cap the total size of the
+// messages it receives, as measured by proto.Size, e.g. to enforce a quota on
+// client-streaming uploads. Once the budget is exceeded RecvMsg fails with
+// ResourceExhausted, which the handler returns to abort the call.
+type ByteBudgetServerStream struct {
+	grpc.ServerStream
+	budget   int64
+	onRecv   func(total int64)
+	received int64
+}
+
+// NewByteBudgetServerStream wraps stream with the given budget in bytes.
+// onRecv, if not nil, is called with the running total after every message.
+func NewByteBudgetServerStream(
+	stream grpc.ServerStream,
+	budget int64,
+	onRecv func(total int64),
+) *ByteBudgetServerStream {
+	return &ByteBudgetServerStream{ServerStream: stream, budget: budget, onRecv: onRecv}
+}
+
+// Received returns the number of bytes received so far.
+func (s *ByteBudgetServerStream) Received() int64 {
+	return s.received
+}
+
+// RecvMsg receives the next message unless the budget is exceeded.
+func (s *ByteBudgetServerStream) RecvMsg(m any) error {
+	if s.received > s.budget {
+		return status.Errorf(codes.ResourceExhausted, "grpcasync: stream exceeded its budget of %d bytes", s.budget)
+	} else if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	if msg, ok := m.(proto.Message); ok {
+		s.received += int64(proto.Size(msg))
+	}
+
+	if s.onRecv != nil {
+		s.onRecv(s.received)
+	}
+
+	if s.received > s.budget {
+		return status.Errorf(codes.ResourceExhausted, "grpcasync: stream exceeded its budget of %d bytes", s.budget)
+	}
+
+	return nil
+}
+
+// ByteBudgetStreamInterceptor returns a server interceptor wrapping every
+// stream in a ByteBudgetServerStream, so that generated handlers are subject
+// to the budget too. onRecv, if not nil, receives the method along with the
+// running total.
+func ByteBudgetStreamInterceptor(budget int64, onRecv func(method string, total int64)) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		var cb func(int64)
+
+		if onRecv != nil {
+			cb = func(total int64) { onRecv(info.FullMethod, total) }
+		}
+
+		return handler(srv, NewByteBudgetServerStream(stream, budget, cb))
+	}
+}
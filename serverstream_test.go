@@ -0,0 +1,442 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// heartbeatGreeter streams the replies produced on events with
+// SendFromWithHeartbeat, sending up to beats heartbeats before giving up on
+// a stalled producer.
+type heartbeatGreeter struct {
+	greeter
+	events func() <-chan *examples.Response
+	beats  int
+}
+
+func (g *heartbeatGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	beats := 0
+
+	return SendFromWithHeartbeat(stream, g.events(), 20*time.Millisecond, func() (*examples.Response, bool) {
+		if beats++; beats > g.beats {
+			return nil, false
+		}
+
+		return &examples.Response{Message: "heartbeat"}, true
+	})
+}
+
+func recvMessages(t *testing.T, stream examples.Greeter_SayHelloStreamReplyClient) ([]string, error) {
+	t.Helper()
+	var msgs []string
+
+	for {
+		res, err := stream.Recv()
+
+		if err == io.EOF {
+			return msgs, nil
+		} else if err != nil {
+			return msgs, err
+		}
+
+		msgs = append(msgs, res.Message)
+	}
+}
+
+func TestSendFromWithHeartbeatStalled(t *testing.T) {
+	impl := &heartbeatGreeter{
+		// The producer never delivers anything.
+		events: func() <-chan *examples.Response { return make(chan *examples.Response) },
+		beats:  2,
+	}
+	client, _ := startGreeter(t, impl, nil)
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := recvMessages(t, stream)
+
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	}
+
+	if len(msgs) != 2 || msgs[0] != "heartbeat" || msgs[1] != "heartbeat" {
+		t.Errorf("messages = %q, want two heartbeats", msgs)
+	}
+}
+
+func TestSendFromWithHeartbeat(t *testing.T) {
+	impl := &heartbeatGreeter{events: func() <-chan *examples.Response {
+		ch := make(chan *examples.Response, 2)
+		ch <- &examples.Response{Message: "Hello, World"}
+		ch <- &examples.Response{Message: "Hello, Alice"}
+		close(ch)
+
+		return ch
+	}}
+	client, _ := startGreeter(t, impl, nil)
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := recvMessages(t, stream)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if len(msgs) != 2 || msgs[0] != "Hello, World" || msgs[1] != "Hello, Alice" {
+		t.Errorf("messages = %q", msgs)
+	}
+}
+
+// subscribeGreeter hands the streams of SayHelloStreamReply over to the test
+// and keeps them open until done is closed.
+type subscribeGreeter struct {
+	greeter
+	joined chan grpc.ServerStream
+	done   chan struct{}
+}
+
+func (g *subscribeGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	g.joined <- stream
+	<-g.done
+	return nil
+}
+
+// subscribe opens n streams to a subscribeGreeter, the received messages are
+// counted on received. It returns the server side of the streams and a
+// function ending them and waiting for the clients to finish.
+func subscribe(t testing.TB, n int, received func(*examples.Response)) ([]grpc.ServerStream, func()) {
+	t.Helper()
+	impl := &subscribeGreeter{joined: make(chan grpc.ServerStream), done: make(chan struct{})}
+	client, _ := startGreeter(t, impl, nil)
+	var wg sync.WaitGroup
+	streams := make([]grpc.ServerStream, n)
+
+	for i := range streams {
+		stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		streams[i] = <-impl.joined
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				res, err := stream.Recv()
+
+				if err != nil {
+					return
+				}
+
+				received(res)
+			}
+		}()
+	}
+
+	return streams, func() {
+		close(impl.done)
+		wg.Wait()
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	var mu sync.Mutex
+	var msgs []string
+	streams, stop := subscribe(t, 3, func(res *examples.Response) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		msgs = append(msgs, res.Message)
+	})
+
+	for _, msg := range []string{"Hello, World", "Hello, Alice"} {
+		if err := Broadcast(streams, &examples.Response{Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stop()
+
+	if len(msgs) != 6 {
+		t.Fatalf("received %d messages, want 6", len(msgs))
+	}
+
+	for _, msg := range msgs {
+		if msg != "Hello, World" && msg != "Hello, Alice" {
+			t.Errorf("unexpected message %q", msg)
+		}
+	}
+}
+
+func TestBroadcastNoStreams(t *testing.T) {
+	if err := Broadcast(nil, &examples.Response{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	msg := &examples.Response{Message: strings.Repeat("Hello, World ", 100)}
+	send := map[string]func(streams []grpc.ServerStream) error{
+		"broadcast": func(streams []grpc.ServerStream) error {
+			return Broadcast(streams, msg)
+		},
+		"per-stream": func(streams []grpc.ServerStream) error {
+			for _, stream := range streams {
+				if err := stream.SendMsg(msg); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	for _, name := range []string{"per-stream", "broadcast"} {
+		send := send[name]
+
+		b.Run(name, func(b *testing.B) {
+			streams, stop := subscribe(b, 20, func(*examples.Response) {})
+			defer stop()
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := send(streams); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// lateGreeter sends one reply through a SafeServerStream and leaves another
+// goroutine sending after the handler has returned.
+type lateGreeter struct {
+	greeter
+	late chan error
+}
+
+func (g *lateGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	returned := make(chan struct{})
+	defer close(returned)
+
+	safe := NewSafeServerStream(stream)
+	defer safe.Close()
+
+	go func() {
+		<-returned
+		g.late <- safe.SendMsg(&examples.Response{Message: "too late"})
+	}()
+
+	return safe.SendMsg(&examples.Response{Message: "Hello, " + req.Name})
+}
+
+func TestSafeServerStreamLateSend(t *testing.T) {
+	impl := &lateGreeter{late: make(chan error, 1)}
+	client, _ := startGreeter(t, impl, nil)
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{Name: "World"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := recvMessages(t, stream)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if len(msgs) != 1 || msgs[0] != "Hello, World" {
+		t.Errorf("messages = %q", msgs)
+	}
+
+	if err := <-impl.late; !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("late send err = %v, want ErrStreamClosed", err)
+	}
+}
+
+// debounceGreeter bursts five replies through DebouncedSend, then pauses
+// past the window and sends one more before closing.
+type debounceGreeter struct {
+	greeter
+}
+
+func (g *debounceGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	ch := make(chan *examples.Response)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- DebouncedSend(stream, ch, 50*time.Millisecond, joinResponses)
+	}()
+
+	for _, n := range []string{"1", "2", "3", "4", "5"} {
+		ch <- &examples.Response{Message: n}
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	ch <- &examples.Response{Message: "6"}
+	close(ch)
+
+	return <-done
+}
+
+func TestDebouncedSend(t *testing.T) {
+	client, _ := startGreeter(t, &debounceGreeter{}, nil)
+	stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last message is flushed as soon as the channel closes.
+	msgs, err := recvMessages(t, stream)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if len(msgs) != 2 || msgs[0] != "1,2,3,4,5" || msgs[1] != "6" {
+		t.Errorf("messages = %q, want the burst merged into one", msgs)
+	}
+}
+
+// rateLimitedGreeter accepts the requests of SayHelloDuplex with
+// RecvRateLimit and reports when it got each of them.
+type rateLimitedGreeter struct {
+	greeter
+	limit    rate.Limit
+	received chan time.Time
+}
+
+func (g *rateLimitedGreeter) SayHelloDuplex(stream examples.Greeter_SayHelloDuplexServer) error {
+	for {
+		req, err := RecvRateLimit[examples.Request](stream, g.limit)
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		g.received <- time.Now()
+
+		if err := stream.Send(&examples.Response{Message: "Hello, " + req.Name}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestRecvRateLimit(t *testing.T) {
+	const n = 6
+	impl := &rateLimitedGreeter{limit: 20, received: make(chan time.Time, n)}
+	client, _ := startGreeter(t, impl, nil)
+	stream, err := client.SayHelloDuplex(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The client floods the server, which must still take them one at a time.
+	for i := 0; i < n; i++ {
+		if err := stream.Send(&examples.Request{Name: "World"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < n; i++ {
+		if _, err := stream.Recv(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// At 20 messages per second, a message is let through every 50ms.
+	prev := <-impl.received
+
+	for i := 1; i < n; i++ {
+		at := <-impl.received
+
+		if gap := at.Sub(prev); gap < 40*time.Millisecond {
+			t.Errorf("message %d accepted %v after the previous one, want about 50ms", i, gap)
+		}
+
+		prev = at
+	}
+}
+
+func TestByteBudgetStreamInterceptor(t *testing.T) {
+	// A request named "alice" takes 7 bytes, one named "bob" 5.
+	tests := []struct {
+		name   string
+		names  []string
+		totals []int64
+		code   codes.Code
+	}{
+		{"within budget", []string{"bob"}, []int64{5}, codes.OK},
+		{"exceeded", []string{"alice", "bob", "carol"}, []int64{7, 12}, codes.ResourceExhausted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			totals := make(chan int64, len(tt.names))
+			onRecv := func(method string, total int64) {
+				if method == streamRequestMethod {
+					totals <- total
+				}
+			}
+
+			client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+				grpc.StreamInterceptor(ByteBudgetStreamInterceptor(10, onRecv)),
+			})
+			stream, err := client.SayHelloStreamRequest(context.Background())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range tt.names {
+				// Once the server has aborted, the status is reported by
+				// CloseAndRecv.
+				if err := stream.Send(&examples.Request{Name: name}); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := stream.CloseAndRecv(); status.Code(err) != tt.code {
+				t.Fatalf("err = %v, want %v", err, tt.code)
+			}
+
+			close(totals)
+			var got []int64
+
+			for total := range totals {
+				got = append(got, total)
+			}
+
+			if !reflect.DeepEqual(got, tt.totals) {
+				t.Errorf("running totals = %v, want %v", got, tt.totals)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package grpcasync
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// WithServiceConfig checks that config is a JSON object and returns a dial
+// option applying it as the default service config. Use ServiceConfig to
+// build the JSON for common cases.
+func WithServiceConfig(config string) (grpc.DialOption, error) {
+	var obj map[string]any
+
+	if err := json.Unmarshal([]byte(config), &obj); err != nil {
+		return nil, fmt.Errorf("grpcasync: invalid service config: %w", err)
+	}
+
+	return grpc.WithDefaultServiceConfig(config), nil
+}
+
+// RetryPolicy is the retry policy of a method in a ServiceConfig.
+type RetryPolicy struct {
+	MaxAttempts          int
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	BackoffMultiplier    float64
+	RetryableStatusCodes []codes.Code
+}
+
+// ServiceConfig builds the JSON of a gRPC service config, see
+// https://github.com/grpc/grpc/blob/master/doc/service_config.md.
+//
+// An empty method name applies the setting to every method of the service.
+type ServiceConfig struct {
+	// LoadBalancingPolicy is the name of the balancer, e.g. "round_robin".
+	LoadBalancingPolicy string
+
+	methods []*methodConfig
+}
+
+type methodName struct {
+	Service string `json:"service"`
+	Method  string `json:"method,omitempty"`
+}
+
+type retryPolicyConfig struct {
+	MaxAttempts          int      `json:"maxAttempts"`
+	InitialBackoff       string   `json:"initialBackoff"`
+	MaxBackoff           string   `json:"maxBackoff"`
+	BackoffMultiplier    float64  `json:"backoffMultiplier"`
+	RetryableStatusCodes []string `json:"retryableStatusCodes"`
+}
+
+type methodConfig struct {
+	Name        []methodName       `json:"name"`
+	Timeout     string             `json:"timeout,omitempty"`
+	RetryPolicy *retryPolicyConfig `json:"retryPolicy,omitempty"`
+}
+
+// Timeout sets the default timeout of the method.
+func (c *ServiceConfig) Timeout(service, method string, d time.Duration) *ServiceConfig {
+	c.method(service, method).Timeout = jsonDuration(d)
+	return c
+}
+
+// Retry sets the retry policy of the method.
+func (c *ServiceConfig) Retry(service, method string, policy RetryPolicy) *ServiceConfig {
+	names := make([]string, len(policy.RetryableStatusCodes))
+
+	for i, code := range policy.RetryableStatusCodes {
+		names[i] = codeName(code)
+	}
+
+	c.method(service, method).RetryPolicy = &retryPolicyConfig{
+		MaxAttempts:          policy.MaxAttempts,
+		InitialBackoff:       jsonDuration(policy.InitialBackoff),
+		MaxBackoff:           jsonDuration(policy.MaxBackoff),
+		BackoffMultiplier:    policy.BackoffMultiplier,
+		RetryableStatusCodes: names,
+	}
+
+	return c
+}
+
+// JSON returns the service config in its JSON form, ready to be passed to
+// WithServiceConfig.
+func (c *ServiceConfig) JSON() string {
+	var config struct {
+		LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig,omitempty"`
+		MethodConfig        []*methodConfig       `json:"methodConfig,omitempty"`
+	}
+
+	if c.LoadBalancingPolicy != "" {
+		config.LoadBalancingConfig = []map[string]struct{}{{c.LoadBalancingPolicy: {}}}
+	}
+
+	config.MethodConfig = c.methods
+	data, _ := json.Marshal(config)
+
+	return string(data)
+}
+
+func (c *ServiceConfig) method(service, method string) *methodConfig {
+	name := methodName{Service: service, Method: method}
+
+	for _, m := range c.methods {
+		if m.Name[0] == name {
+			return m
+		}
+	}
+
+	m := &methodConfig{Name: []methodName{name}}
+	c.methods = append(c.methods, m)
+
+	return m
+}
+
+// jsonDuration formats d the way the JSON mapping of google.protobuf.Duration
+// expects, e.g. "0.5s".
+func jsonDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// codeName converts a code to its canonical name, e.g. DeadlineExceeded to
+// DEADLINE_EXCEEDED.
+func codeName(code codes.Code) string {
+	var b strings.Builder
+	prev := ' '
+
+	for _, r := range code.String() {
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			b.WriteByte('_')
+		}
+
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package grpcasync
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServiceConfigTimeout(t *testing.T) {
+	config := (&ServiceConfig{}).Timeout("examples.Greeter", "SayHello", 50*time.Millisecond)
+	opt, err := WithServiceConfig(config.JSON())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ := startGreeter(t, &sleepyGreeter{}, nil, opt)
+	start := time.Now()
+	_, err = client.SayHello(context.Background(), &examples.Request{Name: "2s"})
+
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", err)
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("call failed after %v, not bound by the configured timeout", elapsed)
+	}
+}
+
+func TestServiceConfigRetry(t *testing.T) {
+	var calls atomic.Int32
+	impl := &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		if calls.Add(1) == 1 {
+			return nil, status.Error(codes.Unavailable, "try again")
+		}
+
+		return &examples.Response{Message: "Hello, " + req.Name}, nil
+	}}
+
+	config := (&ServiceConfig{}).Retry("examples.Greeter", "", RetryPolicy{
+		MaxAttempts:          3,
+		InitialBackoff:       10 * time.Millisecond,
+		MaxBackoff:           100 * time.Millisecond,
+		BackoffMultiplier:    2,
+		RetryableStatusCodes: []codes.Code{codes.Unavailable},
+	})
+	opt, err := WithServiceConfig(config.JSON())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ := startGreeter(t, impl, nil, opt)
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{Name: "World"}); err != nil {
+		t.Fatal(err)
+	} else if got := calls.Load(); got != 2 {
+		t.Errorf("calls = %d, want 2", got)
+	}
+}
+
+func TestWithServiceConfigInvalid(t *testing.T) {
+	if _, err := WithServiceConfig("[1, 2]"); err == nil {
+		t.Error("no error for a config that is not an object")
+	}
+}
+
+func TestCodeName(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want string
+	}{
+		{codes.OK, "OK"},
+		{codes.Unavailable, "UNAVAILABLE"},
+		{codes.DeadlineExceeded, "DEADLINE_EXCEEDED"},
+		{codes.ResourceExhausted, "RESOURCE_EXHAUSTED"},
+	}
+
+	for _, tt := range tests {
+		if got := codeName(tt.code); got != tt.want {
+			t.Errorf("codeName(%v) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
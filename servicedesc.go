@@ -0,0 +1,79 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/grpc"
+)
+
+var (
+	contextType      = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType        = reflect.TypeOf((*error)(nil)).Elem()
+	serverStreamType = reflect.TypeOf((*grpc.ServerStream)(nil)).Elem()
+)
+
+// CheckServiceDesc verifies by reflection that impl has a method of the right
+// shape for every method of sd: unary, server-streaming, or client-streaming
+// and bidirectional, which share the same shape. It reports all mismatches at
+// once, before grpc.Server.RegisterService would panic on the first one or a
+// call would reach a method of the wrong kind. Methods promoted from an
+// embedded Unimplemented server count as present.
+func CheckServiceDesc(sd grpc.ServiceDesc, impl any) error {
+	typ := reflect.TypeOf(impl)
+
+	if typ == nil {
+		return fmt.Errorf("grpcasync: nil implementation of %s", sd.ServiceName)
+	}
+
+	var errs []error
+
+	check := func(name, kind string, ok func(reflect.Type) bool) {
+		method, found := typ.MethodByName(name)
+
+		if !found {
+			errs = append(errs, fmt.Errorf("grpcasync: %s: missing %s method %s", sd.ServiceName, kind, name))
+		} else if !ok(method.Type) {
+			errs = append(errs, fmt.Errorf("grpcasync: %s: method %s is not a %s method: %v",
+				sd.ServiceName, name, kind, method.Type))
+		}
+	}
+
+	for _, m := range sd.Methods {
+		// func(recv, context.Context, *Req) (*Res, error)
+		check(m.MethodName, "unary", func(t reflect.Type) bool {
+			return t.NumIn() == 3 && t.In(1) == contextType && t.In(2).Kind() == reflect.Pointer &&
+				t.NumOut() == 2 && t.Out(0).Kind() == reflect.Pointer && t.Out(1) == errorType
+		})
+	}
+
+	for _, s := range sd.Streams {
+		if s.ServerStreams && !s.ClientStreams {
+			// func(recv, *Req, Stream) error
+			check(s.StreamName, "server-streaming", func(t reflect.Type) bool {
+				return t.NumIn() == 3 && t.In(1).Kind() == reflect.Pointer && isServerStream(t.In(2)) &&
+					t.NumOut() == 1 && t.Out(0) == errorType
+			})
+		} else {
+			// func(recv, Stream) error
+			check(s.StreamName, "client-streaming or bidirectional", func(t reflect.Type) bool {
+				return t.NumIn() == 2 && isServerStream(t.In(1)) &&
+					t.NumOut() == 1 && t.Out(0) == errorType
+			})
+		}
+	}
+
+	if sd.HandlerType != nil && len(errs) == 0 {
+		if iface := reflect.TypeOf(sd.HandlerType).Elem(); !typ.Implements(iface) {
+			errs = append(errs, fmt.Errorf("grpcasync: %v does not implement %v", typ, iface))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func isServerStream(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.Implements(serverStreamType)
+}
@@ -0,0 +1,58 @@
+package grpcasync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+)
+
+// partialGreeter only implements SayHello, and SayHelloStreamReply as if it
+// were a unary method.
+type partialGreeter struct{}
+
+func (partialGreeter) SayHello(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	return &examples.Response{Message: "Hello, " + req.Name}, nil
+}
+
+func (partialGreeter) SayHelloStreamReply(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	return &examples.Response{Message: "Hello, " + req.Name}, nil
+}
+
+func TestCheckServiceDesc(t *testing.T) {
+	tests := []struct {
+		name string
+		impl any
+		errs []string
+	}{
+		{"complete", &greeter{}, nil},
+		{"partial", partialGreeter{}, []string{
+			"method SayHelloStreamReply is not a server-streaming method",
+			"missing client-streaming or bidirectional method SayHelloStreamRequest",
+			"missing client-streaming or bidirectional method SayHelloDuplex",
+		}},
+		{"nil", nil, []string{"nil implementation of examples.Greeter"}},
+	}
+
+	for _, tt := range tests {
+		err := CheckServiceDesc(examples.Greeter_ServiceDesc, tt.impl)
+
+		if tt.errs == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+
+			continue
+		} else if err == nil {
+			t.Errorf("%s: got no error", tt.name)
+			continue
+		}
+
+		for _, want := range tt.errs {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not report %q", tt.name, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package grpcasync
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RetryAfterMetadataKey is the header through which LoadSheddingInterceptor
+// tells clients how many seconds to wait before retrying.
+const RetryAfterMetadataKey = "retry-after"
+
+// LoadSheddingInterceptor returns a server interceptor that rejects unary
+// calls with Unavailable and a retry-after header while shouldShed reports
+// that the server is overloaded, before any work is done for them. The header
+// suggests waiting retryAfter, rounded to whole seconds and at least one.
+func LoadSheddingInterceptor(shouldShed func() bool, retryAfter time.Duration) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if shouldShed() {
+			seconds := int(retryAfter.Round(time.Second) / time.Second)
+			value := strconv.Itoa(max(seconds, 1))
+			grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadataKey, value))
+
+			return nil, status.Error(codes.Unavailable, "grpcasync: server overloaded, retry later")
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// GoroutineLimit returns a predicate for LoadSheddingInterceptor reporting
+// overload once more than n goroutines are running, a cheap proxy for the
+// number of calls in progress.
+func GoroutineLimit(n int) func() bool {
+	return func() bool {
+		return runtime.NumGoroutine() > n
+	}
+}
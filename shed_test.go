@@ -0,0 +1,62 @@
+package grpcasync
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// callRetryAfter calls SayHello and returns the retry-after header it got.
+func callRetryAfter(client examples.GreeterClient) (string, error) {
+	_, header, _, err := CallWithMeta(func(opts ...grpc.CallOption) (*examples.Response, error) {
+		return client.SayHello(context.Background(), &examples.Request{}, opts...)
+	})
+
+	if values := header.Get(RetryAfterMetadataKey); len(values) > 0 {
+		return values[0], err
+	}
+
+	return "", err
+}
+
+func TestLoadSheddingInterceptor(t *testing.T) {
+	var overloaded atomic.Bool
+	impl := &countingGreeter{}
+	client, _ := startGreeter(t, impl, []grpc.ServerOption{
+		grpc.UnaryInterceptor(LoadSheddingInterceptor(overloaded.Load, 3*time.Second)),
+	})
+
+	if retryAfter, err := callRetryAfter(client); err != nil || retryAfter != "" {
+		t.Fatalf("err = %v, retry-after = %q without overload", err, retryAfter)
+	}
+
+	overloaded.Store(true)
+	retryAfter, err := callRetryAfter(client)
+
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("err = %v, want Unavailable", err)
+	} else if retryAfter != "3" {
+		t.Errorf("retry-after = %q, want 3", retryAfter)
+	}
+
+	if got := impl.calls.Load(); got != 1 {
+		t.Errorf("%d calls reached the handler, want only the first", got)
+	}
+}
+
+func TestLoadSheddingInterceptorMinimumRetryAfter(t *testing.T) {
+	shed := func() bool { return true }
+	client, _ := startGreeter(t, &greeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(LoadSheddingInterceptor(shed, 100*time.Millisecond)),
+	})
+
+	if retryAfter, _ := callRetryAfter(client); retryAfter != "1" {
+		t.Errorf("retry-after = %q, want 1", retryAfter)
+	}
+}
@@ -0,0 +1,57 @@
+package grpcasync
+
+import (
+	"context"
+
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// SingleFlightInterceptor returns a client interceptor that coalesces
+// concurrent identical unary calls: while a call is in flight, further calls
+// of the same method with the same key wait for it and receive a copy of its
+// response or error instead of going to the network.
+//
+// Coalescing is opt-in, keyFn returns an empty key for calls that must not be
+// coalesced, and should only return one for idempotent methods. Waiting calls
+// share the fate of the first one, including its cancellation.
+func SingleFlightInterceptor(keyFn func(method string, req any) string) grpc.UnaryClientInterceptor {
+	var group singleflight.Group
+
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		key := keyFn(method, req)
+		msg, ok := reply.(proto.Message)
+
+		if key == "" || !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		leader := false
+		shared, err, _ := group.Do(method+"\x00"+key, func() (any, error) {
+			leader = true
+
+			if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+				return nil, err
+			}
+
+			// The leader's caller owns reply and may modify it as soon as
+			// the call returns, so the followers copy from a clone.
+			return proto.Clone(msg), nil
+		})
+
+		if err == nil && !leader {
+			proto.Reset(msg)
+			proto.Merge(msg, shared.(proto.Message))
+		}
+
+		return err
+	}
+}
@@ -0,0 +1,100 @@
+package grpcasync
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+)
+
+func TestSingleFlightInterceptor(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	impl := &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		calls.Add(1)
+		<-release
+		return &examples.Response{Message: "Hello, " + req.Name}, nil
+	}}
+
+	keyFn := func(method string, req any) string {
+		return req.(*examples.Request).Name
+	}
+	// Count the calls entering the single flight interceptor.
+	var entered atomic.Int32
+	count := func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		entered.Add(1)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	client, _ := startGreeter(t, impl, nil, grpc.WithChainUnaryInterceptor(count, SingleFlightInterceptor(keyFn)))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			res, err := client.SayHello(context.Background(), &examples.Request{Name: "World"})
+
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			// Every caller owns its response, even a shared one.
+			if res.Message != "Hello, World" {
+				t.Errorf("message = %q", res.Message)
+			}
+
+			res.Message = ""
+		}()
+	}
+
+	// Let the followers join the flight before answering the first call.
+	for entered.Load() < 50 || calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("handler invoked %d times, want 1", got)
+	}
+}
+
+func TestSingleFlightInterceptorOptOut(t *testing.T) {
+	impl := &countingGreeter{}
+	keyFn := func(method string, req any) string { return "" }
+	client, _ := startGreeter(t, impl, nil, grpc.WithUnaryInterceptor(SingleFlightInterceptor(keyFn)))
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.SayHello(context.Background(), &examples.Request{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := impl.calls.Load(); got != 3 {
+		t.Errorf("handler invoked %d times, want 3", got)
+	}
+}
@@ -0,0 +1,56 @@
+package grpcasync
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// SlowCallInterceptor returns a server interceptor that calls onSlow for
+// every unary call whose handler takes longer than threshold. Fast calls only
+// pay for reading the clock twice. The duration is taken from the CallTimer
+// of the call when CallTimerInterceptor is installed.
+func SlowCallInterceptor(
+	threshold time.Duration,
+	onSlow func(method string, dur time.Duration, req any),
+) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		timer := CallTimerFromContext(ctx)
+		res, err := handler(ctx, req)
+
+		if dur := timer.Stop(); dur > threshold {
+			onSlow(info.FullMethod, dur, req)
+		}
+
+		return res, err
+	}
+}
+
+// SlowStreamInterceptor is the streaming variant of SlowCallInterceptor, it
+// measures the total duration of the stream and passes a nil request.
+func SlowStreamInterceptor(
+	threshold time.Duration,
+	onSlow func(method string, dur time.Duration, req any),
+) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		timer := CallTimerFromContext(stream.Context())
+		err := handler(srv, stream)
+
+		if dur := timer.Stop(); dur > threshold {
+			onSlow(info.FullMethod, dur, nil)
+		}
+
+		return err
+	}
+}
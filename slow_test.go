@@ -0,0 +1,95 @@
+package grpcasync
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+)
+
+// slowCalls records the calls reported by the slow call interceptors.
+type slowCalls struct {
+	mu      sync.Mutex
+	methods []string
+}
+
+func (s *slowCalls) onSlow(method string, dur time.Duration, req any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.methods = append(s.methods, method)
+}
+
+func (s *slowCalls) get() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.methods
+}
+
+// sleepyGreeter sleeps for the duration named by the request before replying
+// like greeter.
+type sleepyGreeter struct {
+	greeter
+}
+
+func (g *sleepyGreeter) SayHello(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+	d, _ := time.ParseDuration(req.Name)
+	time.Sleep(d)
+	return g.greeter.SayHello(ctx, req)
+}
+
+func (g *sleepyGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	d, _ := time.ParseDuration(req.Name)
+	time.Sleep(d)
+	return g.greeter.SayHelloStreamReply(req, stream)
+}
+
+func TestSlowCallInterceptor(t *testing.T) {
+	calls := &slowCalls{}
+	client, _ := startGreeter(t, &sleepyGreeter{}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(SlowCallInterceptor(30*time.Millisecond, calls.onSlow)),
+	})
+
+	for _, d := range []string{"0s", "60ms", "0s"} {
+		if _, err := client.SayHello(context.Background(), &examples.Request{Name: d}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := calls.get(); len(got) != 1 || got[0] != sayHelloMethod {
+		t.Errorf("slow calls = %v, want [%s]", got, sayHelloMethod)
+	}
+}
+
+func TestSlowStreamInterceptor(t *testing.T) {
+	calls := &slowCalls{}
+	_, conn := startGreeter(t, &sleepyGreeter{}, []grpc.ServerOption{
+		grpc.StreamInterceptor(SlowStreamInterceptor(30*time.Millisecond, calls.onSlow)),
+	})
+
+	for _, d := range []string{"0s", "60ms"} {
+		open := func(ctx context.Context) (grpc.ClientStream, error) {
+			return openStreamReply(ctx, conn, d)
+		}
+
+		if _, err := TakeN[examples.Response](context.Background(), open, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The interceptor reports once the handler has returned, which may be
+	// after the client received the last message.
+	deadline := time.Now().Add(time.Second)
+
+	for len(calls.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := calls.get(); len(got) != 1 || got[0] != streamReplyMethod {
+		t.Errorf("slow streams = %v, want [%s]", got, streamReplyMethod)
+	}
+}
@@ -0,0 +1,58 @@
+package grpcasync
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// SPIFFEVerifier returns a tls.Config.VerifyPeerCertificate callback that
+// accepts the peer only if its certificate carries one of the allowed SPIFFE
+// IDs, such as "spiffe://example.org/service/greeter", as a URI SAN. It is
+// meant for credentials.NewTLS, in addition to the regular chain
+// verification, when peers are identified by SPIFFE IDs rather than DNS
+// names:
+//
+//	config := &tls.Config{
+//		RootCAs:               roots,
+//		ServerName:            "greeter",
+//		VerifyPeerCertificate: grpcasync.SPIFFEVerifier(allowed),
+//	}
+//	creds := credentials.NewTLS(config)
+func SPIFFEVerifier(allowed []string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	ids := make(map[string]struct{}, len(allowed))
+
+	for _, id := range allowed {
+		ids[id] = struct{}{}
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("grpcasync: peer presented no certificate")
+		}
+
+		cert, err := x509.ParseCertificate(rawCerts[0])
+
+		if err != nil {
+			return fmt.Errorf("grpcasync: parse peer certificate: %w", err)
+		}
+
+		var found []string
+
+		for _, uri := range cert.URIs {
+			if uri.Scheme != "spiffe" {
+				continue
+			} else if _, ok := ids[uri.String()]; ok {
+				return nil
+			}
+
+			found = append(found, uri.String())
+		}
+
+		if len(found) == 0 {
+			return errors.New("grpcasync: peer certificate carries no SPIFFE ID")
+		}
+
+		return fmt.Errorf("grpcasync: peer SPIFFE ID %v is not allowed", found)
+	}
+}
@@ -0,0 +1,115 @@
+package grpcasync
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// selfSignedCert returns a certificate for the DNS name "greeter" carrying
+// the URI SANs uris.
+func selfSignedCert(t *testing.T, uris ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "greeter"},
+		DNSNames:              []string{"greeter"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		template.URIs = append(template.URIs, u)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestSPIFFEVerifier(t *testing.T) {
+	const id = "spiffe://example.org/service/greeter"
+	cert := selfSignedCert(t, id)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	sopts := []grpc.ServerOption{grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		errMsg  string
+	}{
+		{"allowed", []string{"spiffe://example.org/service/other", id}, ""},
+		{"not allowed", []string{"spiffe://example.org/service/other"}, "is not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := credentials.NewTLS(&tls.Config{
+				RootCAs:               roots,
+				ServerName:            "greeter",
+				VerifyPeerCertificate: SPIFFEVerifier(tt.allowed),
+			})
+			client, _ := startGreeter(t, &greeter{}, sopts, grpc.WithTransportCredentials(creds))
+			_, err := client.SayHello(context.Background(), &examples.Request{Name: "World"})
+
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("err = %v, want Unavailable mentioning %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestSPIFFEVerifierNoID(t *testing.T) {
+	cert := selfSignedCert(t, "https://example.org/greeter")
+	err := SPIFFEVerifier([]string{"spiffe://example.org/service/greeter"})(cert.Certificate, nil)
+
+	if err == nil || !strings.Contains(err.Error(), "no SPIFFE ID") {
+		t.Errorf("err = %v, want one reporting the missing SPIFFE ID", err)
+	}
+}
@@ -0,0 +1,307 @@
+package grpcasync
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// TakeN opens a server stream with a cancelable context derived from ctx,
+// receives at most n messages and then cancels the stream so the server stops
+// producing. Ending the stream this way is not reported as an error; only
+// failures that occur before n messages are received are. If n is not
+// positive, the stream is not opened at all.
+func TakeN[Res any](
+	ctx context.Context,
+	open func(ctx context.Context) (grpc.ClientStream, error),
+	n int,
+) ([]*Res, error) {
+	if n <= 0 {
+		return []*Res{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := open(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Res, 0, n)
+
+	for len(results) < n {
+		res := new(Res)
+
+		if err := stream.RecvMsg(res); err == io.EOF {
+			break
+		} else if err != nil {
+			return results, err
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// CloseAndRecvCtx half-closes a client stream and waits for its response, but
+// returns ctx.Err() as soon as ctx is done instead of blocking until the
+// server replies. The pending receive is left to finish on its own; opening
+// the stream with ctx (or a context derived from it) makes it return at the
+// same time.
+func CloseAndRecvCtx[Res any](ctx context.Context, stream grpc.ClientStream, opts ...StreamOption) (*Res, error) {
+	type result struct {
+		res *Res
+		err error
+	}
+
+	done := make(chan result, 1)
+
+	err := workerPool(opts).GoCtx(ctx, func() {
+		if err := stream.CloseSend(); err != nil {
+			done <- result{nil, err}
+			return
+		}
+
+		res := new(Res)
+
+		if err := stream.RecvMsg(res); err != nil {
+			done <- result{nil, err}
+		} else {
+			done <- result{res, nil}
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Exchange sends all reqs over a bidirectional stream, half-closes it and
+// collects the responses in order, expecting exactly one response per request.
+// Sending and receiving happen concurrently so that flow control cannot stall
+// large batches. If ctx is done first, ctx.Err() is returned. With
+// WithWorkerPool, the two goroutines doing so need a pool of at least two
+// workers.
+func Exchange[Req any, Res any](
+	ctx context.Context,
+	stream grpc.ClientStream,
+	reqs []*Req,
+	opts ...StreamOption,
+) ([]*Res, error) {
+	type result struct {
+		res []*Res
+		err error
+	}
+
+	sent := make(chan error, 1)
+	done := make(chan result, 1)
+
+	send := func() {
+		for _, req := range reqs {
+			if err := stream.SendMsg(req); err != nil {
+				sent <- err
+				return
+			}
+		}
+
+		sent <- stream.CloseSend()
+	}
+
+	recv := func() {
+		results := make([]*Res, 0, len(reqs))
+
+		for {
+			res := new(Res)
+
+			if err := stream.RecvMsg(res); err == io.EOF {
+				break
+			} else if err != nil {
+				done <- result{results, err}
+				return
+			}
+
+			results = append(results, res)
+		}
+
+		done <- result{results, nil}
+	}
+
+	// Both workers are taken together, as holding one while waiting for the
+	// other could deadlock with another Exchange on the same pool.
+	if err := workerPool(opts).GoCtx(ctx, send, recv); err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-done:
+		// A send error usually surfaces as io.EOF from SendMsg while the real
+		// status is reported by RecvMsg, so receive errors take precedence.
+		if r.err != nil {
+			return r.res, r.err
+		} else if err := <-sent; err != nil && err != io.EOF {
+			return r.res, err
+		} else if len(r.res) != len(reqs) {
+			return r.res, fmt.Errorf("grpcasync: received %d responses for %d requests",
+				len(r.res), len(reqs))
+		}
+
+		return r.res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ResumableStream receives a server stream and transparently reopens it when
+// it fails with Unavailable or Aborted, up to maxRetries times. The stream is
+// first opened with an empty resume token, and then with the token of the
+// last message received, as read by token, so that the server continues
+// where it left off. If the server repeats the message of that token first,
+// it is skipped.
+//
+// The messages are yielded on the returned channel, which is closed when the
+// stream ends, fails for good or ctx is done; the error channel then receives
+// the failure, or nil.
+func ResumableStream[Res any](
+	ctx context.Context,
+	open func(resumeToken string) (grpc.ClientStream, error),
+	token func(res *Res) string,
+	maxRetries int,
+	opts ...StreamOption,
+) (<-chan *Res, <-chan error) {
+	out := make(chan *Res)
+	errc := make(chan error, 1)
+
+	err := workerPool(opts).GoCtx(ctx, func() {
+		defer close(out)
+
+		last := ""
+
+		for retries := 0; ; retries++ {
+			err := resume(ctx, open, token, &last, out)
+
+			if err == nil || !isRetryable(err) || retries >= maxRetries {
+				errc <- err
+				return
+			}
+		}
+	})
+
+	if err != nil {
+		close(out)
+		errc <- err
+	}
+
+	return out, errc
+}
+
+// resume opens the stream from the token *last and forwards its messages to
+// out, updating *last as they arrive.
+func resume[Res any](
+	ctx context.Context,
+	open func(resumeToken string) (grpc.ClientStream, error),
+	token func(res *Res) string,
+	last *string,
+	out chan<- *Res,
+) error {
+	stream, err := open(*last)
+
+	if err != nil {
+		return err
+	}
+
+	resumed := *last != ""
+
+	for first := true; ; first = false {
+		res := new(Res)
+
+		if err := stream.RecvMsg(res); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		t := token(res)
+
+		if first && resumed && t == *last {
+			continue
+		}
+
+		select {
+		case out <- res:
+			*last = t
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// WithMaxStreamBytes returns a dial option capping the total size of the
+// messages received on each stream of the connection at n bytes, as measured
+// by proto.Size, complementing the per-message limit of
+// grpc.MaxCallRecvMsgSize. Once the cap is exceeded the stream is canceled
+// and RecvMsg fails with ResourceExhausted, so that a server cannot exhaust
+// the memory of the client over many messages.
+func WithMaxStreamBytes(n int64) grpc.DialOption {
+	return grpc.WithChainStreamInterceptor(func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		return &maxBytesStream{ClientStream: stream, cancel: cancel, limit: n}, nil
+	})
+}
+
+type maxBytesStream struct {
+	grpc.ClientStream
+	cancel   context.CancelFunc
+	limit    int64
+	received int64
+	err      error
+}
+
+func (s *maxBytesStream) RecvMsg(m any) error {
+	if s.err != nil {
+		return s.err
+	} else if err := s.ClientStream.RecvMsg(m); err != nil {
+		s.cancel()
+		return err
+	}
+
+	if msg, ok := m.(proto.Message); ok {
+		s.received += int64(proto.Size(msg))
+	}
+
+	if s.received > s.limit {
+		s.cancel()
+		s.err = status.Errorf(codes.ResourceExhausted, "grpcasync: stream received more than %d bytes", s.limit)
+		return s.err
+	}
+
+	return nil
+}
@@ -0,0 +1,285 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTakeN(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	open := func(ctx context.Context) (grpc.ClientStream, error) {
+		return openStreamReply(ctx, conn, "World")
+	}
+
+	res, err := TakeN[examples.Response](context.Background(), open, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if len(res) != 2 {
+		t.Fatalf("got %d messages, want 2", len(res))
+	}
+
+	for i, want := range []string{"Hello 1: World", "Hello 2: World"} {
+		if res[i].Message != want {
+			t.Errorf("message %d = %q, want %q", i, res[i].Message, want)
+		}
+	}
+}
+
+func TestTakeNShortStream(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	open := func(ctx context.Context) (grpc.ClientStream, error) {
+		return openStreamReply(ctx, conn, "World")
+	}
+
+	res, err := TakeN[examples.Response](context.Background(), open, 5)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if len(res) != 3 {
+		t.Fatalf("got %d messages, want all 3", len(res))
+	}
+}
+
+func TestTakeNNone(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		open := func(ctx context.Context) (grpc.ClientStream, error) {
+			t.Errorf("stream opened for n = %d", n)
+			return nil, errors.New("unexpected open")
+		}
+
+		if res, err := TakeN[examples.Response](context.Background(), open, n); err != nil || len(res) != 0 {
+			t.Errorf("TakeN(%d) = %v, %v, want no messages", n, res, err)
+		}
+	}
+}
+
+// stallingGreeter reads the whole upload of SayHelloStreamRequest but never
+// replies, until the call ends.
+type stallingGreeter struct {
+	greeter
+}
+
+func (g *stallingGreeter) SayHelloStreamRequest(stream examples.Greeter_SayHelloStreamRequestServer) error {
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func TestCloseAndRecvCtx(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	stream, err := conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := stream.SendMsg(&examples.Request{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := CloseAndRecvCtx[examples.Response](context.Background(), stream)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, Alice, Bob" {
+		t.Errorf("response = %q", res.Message)
+	}
+}
+
+func TestCloseAndRecvCtxCanceled(t *testing.T) {
+	_, conn := startGreeter(t, &stallingGreeter{}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The stream does not use ctx, so only CloseAndRecvCtx can return early.
+	stream, err := conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err := stream.SendMsg(&examples.Request{Name: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = CloseAndRecvCtx[examples.Response](ctx, stream)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v, long after the cancellation", elapsed)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	_, conn := startGreeter(t, &greeter{}, nil)
+	stream, err := conn.NewStream(context.Background(), duplexDesc, duplexMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []*examples.Request{{Name: "Alice"}, {Name: "Bob"}, {Name: "Carol"}}
+	res, err := Exchange[examples.Request, examples.Response](context.Background(), stream, reqs)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if len(res) != len(reqs) {
+		t.Fatalf("got %d responses, want %d", len(res), len(reqs))
+	}
+
+	for i, req := range reqs {
+		if want := "Hello, " + req.Name; res[i].Message != want {
+			t.Errorf("response %d = %q, want %q", i, res[i].Message, want)
+		}
+	}
+}
+
+// resumingGreeter streams the messages "1" to "5" on SayHelloStreamReply,
+// from the one named by the request on, and drops the first stream it serves
+// after two messages.
+type resumingGreeter struct {
+	greeter
+	opens atomic.Int32
+}
+
+func (g *resumingGreeter) SayHelloStreamReply(req *examples.Request, stream examples.Greeter_SayHelloStreamReplyServer) error {
+	first := g.opens.Add(1) == 1
+	start := 1
+
+	if req.Name != "" {
+		start, _ = strconv.Atoi(req.Name)
+	}
+
+	for i := start; i <= 5; i++ {
+		if first && i == 3 {
+			return status.Error(codes.Unavailable, "connection dropped")
+		} else if err := stream.Send(&examples.Response{Message: strconv.Itoa(i)}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestResumableStream(t *testing.T) {
+	impl := &resumingGreeter{}
+	_, conn := startGreeter(t, impl, nil)
+
+	assertNoLeak(t, conn, func() {
+		ctx := context.Background()
+		var tokens []string
+
+		open := func(resumeToken string) (grpc.ClientStream, error) {
+			tokens = append(tokens, resumeToken)
+			return openStreamReply(ctx, conn, resumeToken)
+		}
+		token := func(res *examples.Response) string { return res.Message }
+
+		out, errc := ResumableStream[examples.Response](ctx, open, token, 3)
+		var got []string
+
+		for res := range out {
+			got = append(got, res.Message)
+		}
+
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+
+		// The resumed stream repeats "2" first, which must not be yielded twice.
+		if want := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("messages = %v, want %v", got, want)
+		}
+
+		if want := []string{"", "2"}; !reflect.DeepEqual(tokens, want) {
+			t.Errorf("resume tokens = %q, want %q", tokens, want)
+		}
+	})
+}
+
+func TestResumableStreamGivesUp(t *testing.T) {
+	var opens atomic.Int32
+	open := func(resumeToken string) (grpc.ClientStream, error) {
+		opens.Add(1)
+		return nil, status.Error(codes.Unavailable, "no backend")
+	}
+	token := func(res *examples.Response) string { return res.Message }
+
+	out, errc := ResumableStream[examples.Response](context.Background(), open, token, 2)
+
+	for range out {
+		t.Error("got a message from a stream that never opened")
+	}
+
+	if err := <-errc; status.Code(err) != codes.Unavailable {
+		t.Errorf("err = %v, want Unavailable", err)
+	} else if n := opens.Load(); n != 3 {
+		t.Errorf("opened %d times, want the first attempt and 2 retries", n)
+	}
+}
+
+func TestWithMaxStreamBytes(t *testing.T) {
+	// Each reply of the greeter, e.g. "Hello 1: World", takes 16 bytes.
+	tests := []struct {
+		name     string
+		limit    int64
+		received int
+		code     codes.Code
+	}{
+		{"under the cap", 48, 3, codes.OK},
+		{"exceeded mid-stream", 40, 2, codes.ResourceExhausted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := startGreeter(t, &greeter{}, nil, WithMaxStreamBytes(tt.limit))
+			stream, err := client.SayHelloStreamReply(context.Background(), &examples.Request{Name: "World"})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n := 0
+
+			for {
+				if _, err = stream.Recv(); err != nil {
+					break
+				}
+
+				n++
+			}
+
+			if err == io.EOF {
+				err = nil
+			}
+
+			if status.Code(err) != tt.code {
+				t.Errorf("err = %v, want %v", err, tt.code)
+			} else if n != tt.received {
+				t.Errorf("received %d messages, want %d", n, tt.received)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package grpcasync
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// DefaultPort is the port NormalizeTarget adds to addresses without one,
+// gRPC's own default.
+const DefaultPort = "443"
+
+// NormalizeTarget canonicalizes the many ways users write a target into a
+// valid gRPC target:
+//
+//	localhost:50051     -> dns:///localhost:50051
+//	:50051              -> dns:///localhost:50051
+//	example.com         -> dns:///example.com:443
+//	::1                 -> dns:///[::1]:443
+//	dns:///host:port    -> unchanged
+//	unix:///tmp/s.sock  -> unchanged
+//	unix:/tmp/s.sock    -> unchanged
+//
+// Targets with a scheme, written with "://" or, as gRPC allows for e.g.
+// "unix-abstract:name", with a single colon, are accepted if a resolver is
+// registered for it and they name an endpoint. Anything else must be a host, an optional port and
+// nothing more.
+func NormalizeTarget(addr string) (string, error) {
+	return NormalizeTargetWithPort(addr, DefaultPort)
+}
+
+// NormalizeTargetWithPort is like NormalizeTarget, but adds defaultPort to
+// addresses without a port, e.g. for services that listen on a well-known
+// port of their own.
+func NormalizeTargetWithPort(addr, defaultPort string) (string, error) {
+	invalid := func(reason string) (string, error) {
+		return "", fmt.Errorf("grpcasync: invalid target %q: %s", addr, reason)
+	}
+
+	addr = strings.TrimSpace(addr)
+
+	if addr == "" {
+		return invalid("empty")
+	}
+
+	if scheme, rest, ok := strings.Cut(addr, "://"); ok {
+		if resolver.Get(scheme) == nil {
+			return invalid("unknown scheme " + scheme)
+		} else if strings.Trim(rest, "/") == "" {
+			return invalid("missing endpoint")
+		}
+
+		return addr, nil
+	}
+
+	// A single colon only introduces a scheme if a resolver is registered
+	// for it, anything else is the port of a host.
+	if scheme, rest, ok := strings.Cut(addr, ":"); ok && scheme != "" && resolver.Get(scheme) != nil {
+		if rest == "" {
+			return invalid("missing endpoint")
+		}
+
+		return addr, nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		if ip := net.ParseIP(strings.Trim(addr, "[]")); ip != nil {
+			host, port = ip.String(), ""
+		} else if !strings.Contains(addr, ":") {
+			host, port = addr, ""
+		} else {
+			return invalid(err.Error())
+		}
+	}
+
+	if host == "" {
+		host = "localhost"
+	} else if strings.ContainsAny(host, "/ \t") {
+		return invalid("malformed host")
+	}
+
+	if port == "" {
+		port = defaultPort
+	} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return invalid("malformed port")
+	}
+
+	return "dns:///" + net.JoinHostPort(host, port), nil
+}
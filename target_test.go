@@ -0,0 +1,94 @@
+package grpcasync
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestNormalizeTarget(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"localhost:50051", "dns:///localhost:50051"},
+		{" localhost:50051 ", "dns:///localhost:50051"},
+		{":50051", "dns:///localhost:50051"},
+		{"example.com", "dns:///example.com:443"},
+		{"::1", "dns:///[::1]:443"},
+		{"[::1]:50051", "dns:///[::1]:50051"},
+		{"10.0.0.1", "dns:///10.0.0.1:443"},
+		{"dns:///host:port", "dns:///host:port"},
+		{"unix:///tmp/s.sock", "unix:///tmp/s.sock"},
+		{"unix:/tmp/s.sock", "unix:/tmp/s.sock"},
+		{"unix-abstract:name", "unix-abstract:name"},
+	}
+
+	for _, tt := range tests {
+		if got, err := NormalizeTarget(tt.addr); err != nil {
+			t.Errorf("NormalizeTarget(%q) failed: %v", tt.addr, err)
+		} else if got != tt.want {
+			t.Errorf("NormalizeTarget(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTargetInvalid(t *testing.T) {
+	for _, addr := range []string{
+		"",
+		"nosuchscheme:///host",
+		"dns:///",
+		"unix:",
+		"host:abc",
+		"host:70000",
+		"a b:50051",
+		"host:1:2",
+	} {
+		if got, err := NormalizeTarget(addr); err == nil {
+			t.Errorf("NormalizeTarget(%q) = %q, want an error", addr, got)
+		}
+	}
+}
+
+func TestNormalizeTargetWithPort(t *testing.T) {
+	if got, _ := NormalizeTargetWithPort("example.com", "50051"); got != "dns:///example.com:50051" {
+		t.Errorf("got %q, want the given default port", got)
+	} else if got, _ := NormalizeTargetWithPort("example.com:8080", "50051"); got != "dns:///example.com:8080" {
+		t.Errorf("got %q, want the explicit port kept", got)
+	}
+}
+
+func TestNormalizeTargetDial(t *testing.T) {
+	srv := grpc.NewServer()
+	examples.RegisterGreeterServer(srv, &greeter{})
+	lis := serve(t, srv)
+
+	target, err := NormalizeTarget(":50051")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The target is resolved by DNS, the listener stands in for the address
+	// it resolves to.
+	conn, err := grpc.Dial(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	if _, err := examples.NewGreeterClient(conn).SayHello(context.Background(), &examples.Request{}); err != nil {
+		t.Error(err)
+	}
+}
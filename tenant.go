@@ -0,0 +1,64 @@
+package grpcasync
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantMetadataKey is the metadata key carrying the tenant of a call.
+const TenantMetadataKey = "x-tenant-id"
+
+// WithTenant returns a client context whose calls carry the tenant id.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, TenantMetadataKey, id)
+}
+
+// TenantID returns the tenant of the call handled with ctx, and whether the
+// client sent a non-empty one.
+func TenantID(ctx context.Context) (string, bool) {
+	values := metadata.ValueFromIncomingContext(ctx, TenantMetadataKey)
+
+	if len(values) == 0 || values[0] == "" {
+		return "", false
+	}
+
+	return values[0], true
+}
+
+// RequireTenantInterceptor returns a server interceptor rejecting unary calls
+// that carry no tenant with Unauthenticated.
+func RequireTenantInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if _, ok := TenantID(ctx); !ok {
+			return nil, status.Errorf(codes.Unauthenticated, "grpcasync: missing %s", TenantMetadataKey)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// RequireTenantStreamInterceptor is the streaming variant of
+// RequireTenantInterceptor.
+func RequireTenantStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if _, ok := TenantID(stream.Context()); !ok {
+			return status.Errorf(codes.Unauthenticated, "grpcasync: missing %s", TenantMetadataKey)
+		}
+
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,53 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequireTenantInterceptor(t *testing.T) {
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		id, _ := TenantID(ctx)
+		return &examples.Response{Message: "Hello, " + id}, nil
+	}}, []grpc.ServerOption{
+		grpc.UnaryInterceptor(RequireTenantInterceptor()),
+		grpc.StreamInterceptor(RequireTenantStreamInterceptor()),
+	})
+
+	res, err := client.SayHello(WithTenant(context.Background(), "acme"), &examples.Request{})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, acme" {
+		t.Errorf("response = %q, want the tenant", res.Message)
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"absent", context.Background()},
+		{"empty", WithTenant(context.Background(), "")},
+	}
+
+	for _, tt := range tests {
+		if _, err := client.SayHello(tt.ctx, &examples.Request{}); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: err = %v, want Unauthenticated", tt.name, err)
+		}
+
+		stream, err := client.SayHelloStreamReply(tt.ctx, &examples.Request{})
+
+		if err == nil {
+			_, err = stream.Recv()
+		}
+
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("%s: stream err = %v, want Unauthenticated", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package grpcasync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type callTimerKey struct{}
+
+// CallTimer measures the duration of a call once for all the interceptors of
+// a chain, so that logging and metrics interceptors report the same value.
+// It is started by CallTimerInterceptor, which should be the outermost
+// interceptor, and stopped by the first interceptor calling Stop once the
+// handler has returned.
+type CallTimer struct {
+	start time.Time
+
+	once sync.Once
+	dur  time.Duration
+}
+
+// NewCallTimer returns a CallTimer started now.
+func NewCallTimer() *CallTimer {
+	return &CallTimer{start: time.Now()}
+}
+
+// Start returns the time the call started.
+func (t *CallTimer) Start() time.Time {
+	return t.start
+}
+
+// Stop stops the timer on its first call and returns the duration of the
+// call, the same on every call.
+func (t *CallTimer) Stop() time.Duration {
+	t.once.Do(func() {
+		t.dur = time.Since(t.start)
+	})
+
+	return t.dur
+}
+
+// CallTimerFromContext returns the CallTimer of the call, or a new one
+// started now, and shared with no one, if CallTimerInterceptor is not
+// installed.
+func CallTimerFromContext(ctx context.Context) *CallTimer {
+	if t, ok := ctx.Value(callTimerKey{}).(*CallTimer); ok {
+		return t
+	}
+
+	return NewCallTimer()
+}
+
+// CallTimerInterceptor returns a server interceptor starting a CallTimer for
+// each unary call. Install it first so that the timer covers the whole chain.
+func CallTimerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if _, ok := ctx.Value(callTimerKey{}).(*CallTimer); !ok {
+			ctx = context.WithValue(ctx, callTimerKey{}, NewCallTimer())
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// CallTimerStreamInterceptor is the streaming variant of
+// CallTimerInterceptor.
+func CallTimerStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx := stream.Context()
+
+		if _, ok := ctx.Value(callTimerKey{}).(*CallTimer); !ok {
+			ctx = context.WithValue(ctx, callTimerKey{}, NewCallTimer())
+			stream = &contextServerStream{stream, ctx}
+		}
+
+		return handler(srv, stream)
+	}
+}
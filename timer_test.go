@@ -0,0 +1,53 @@
+package grpcasync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+)
+
+func TestCallTimerInterceptor(t *testing.T) {
+	durations := make(chan time.Duration, 2)
+
+	// reporting stands in for a logging or a metrics interceptor, taking some
+	// time of its own after the handler returned.
+	reporting := func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		res, err := handler(ctx, req)
+		durations <- CallTimerFromContext(ctx).Stop()
+		time.Sleep(10 * time.Millisecond)
+
+		return res, err
+	}
+
+	client, _ := startGreeter(t, &sleepyGreeter{}, []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(CallTimerInterceptor(), reporting, reporting),
+	})
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{Name: "20ms"}); err != nil {
+		t.Fatal(err)
+	}
+
+	inner, outer := <-durations, <-durations
+
+	if inner != outer {
+		t.Errorf("the interceptors reported %v and %v, want the same duration", inner, outer)
+	} else if inner < 20*time.Millisecond {
+		t.Errorf("duration = %v, shorter than the handler", inner)
+	}
+}
+
+func TestCallTimerFromContextUnshared(t *testing.T) {
+	ctx := context.Background()
+
+	if CallTimerFromContext(ctx) == CallTimerFromContext(ctx) {
+		t.Error("got the same timer twice without CallTimerInterceptor")
+	}
+}
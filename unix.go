@@ -0,0 +1,51 @@
+package grpcasync
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ServeUnix serves srv on a Unix domain socket at path, for local IPC. A
+// stale socket file left behind by a previous process is removed first, and
+// the socket file is removed again when srv stops. Like grpc.Server.Serve, it
+// blocks until srv stops.
+func ServeUnix(srv *grpc.Server, path string) error {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return errors.New("grpcasync: " + path + " exists and is not a socket")
+		} else if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	lis, err := net.Listen("unix", path)
+
+	if err != nil {
+		return err
+	}
+
+	return srv.Serve(lis)
+}
+
+// ConnectUnix creates a client connection to a server listening on the Unix
+// domain socket at path. Transport security defaults to insecure, as is usual
+// for local sockets, which opts may override.
+func ConnectUnix(path string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	path, err := filepath.Abs(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+
+	return grpc.Dial("unix://"+path, opts...)
+}
@@ -0,0 +1,72 @@
+package grpcasync
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+)
+
+func TestServeUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "greeter.sock")
+
+	// Leave a stale socket file behind, as a crashed process would.
+	lis, err := net.Listen("unix", path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis.(*net.UnixListener).SetUnlinkOnClose(false)
+	lis.Close()
+
+	srv := grpc.NewServer()
+	examples.RegisterGreeterServer(srv, &greeter{})
+	served := make(chan error, 1)
+
+	go func() { served <- ServeUnix(srv, path) }()
+	defer srv.Stop()
+
+	conn, err := ConnectUnix(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The server may not have replaced the stale socket yet.
+	res, err := examples.NewGreeterClient(conn).SayHello(ctx, &examples.Request{Name: "World"}, grpc.WaitForReady(true))
+
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Message != "Hello, World" {
+		t.Errorf("message = %q", res.Message)
+	}
+
+	srv.Stop()
+	<-served
+
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket file left after stopping: %v", err)
+	}
+}
+
+func TestServeUnixNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "greeter.sock")
+
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ServeUnix(grpc.NewServer(), path); err == nil {
+		t.Error("no error for a path that is not a socket")
+	}
+}
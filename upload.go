@@ -0,0 +1,173 @@
+package grpcasync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SendAllRetrying uploads items over a client stream and returns the server's
+// response. If the attempt fails with Unavailable or Aborted, a new stream is
+// opened with newStream and every item is sent again from the start, up to
+// maxAttempts attempts in total. At least one attempt is always made.
+//
+// Because items may be delivered more than once, the caller must make sure
+// the method is idempotent, i.e. that a failed attempt committed nothing.
+func SendAllRetrying[Req any, Res any](
+	ctx context.Context,
+	newStream func() (grpc.ClientStream, error),
+	items []*Req,
+	maxAttempts int,
+) (*Res, error) {
+	var err error
+
+	for attempt := 0; attempt < max(maxAttempts, 1); attempt++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var res *Res
+
+		if res, err = sendAll[Req, Res](ctx, newStream, items); err == nil {
+			return res, nil
+		}
+
+		if !isRetryable(err) {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// UploadBatches uploads each batch over its own client stream opened with
+// open, running up to concurrency uploads at a time, and returns the
+// responses in the order of the batches. open is given a context canceled on
+// the first failure, which is returned, so that streams opened with it abort
+// along with the uploads not started yet.
+func UploadBatches[Req any, Res any](
+	ctx context.Context,
+	open func(ctx context.Context) (grpc.ClientStream, error),
+	batches [][]*Req,
+	concurrency int,
+) ([]*Res, error) {
+	results := make([]*Res, len(batches))
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(max(concurrency, 1))
+
+	for i, batch := range batches {
+		i, batch := i, batch
+		group.Go(func() error {
+			newStream := func() (grpc.ClientStream, error) { return open(ctx) }
+			res, err := sendAll[Req, Res](ctx, newStream, batch)
+			results[i] = res
+			return err
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// isRetryable reports whether a failed stream is worth opening again, i.e.
+// whether it failed in a way that is usually transient.
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+func sendAll[Req any, Res any](
+	ctx context.Context,
+	newStream func() (grpc.ClientStream, error),
+	items []*Req,
+) (*Res, error) {
+	stream, err := newStream()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if err := stream.SendMsg(item); err == io.EOF {
+			// The stream was aborted, the actual status is reported by
+			// receiving.
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return CloseAndRecvCtx[Res](ctx, stream)
+}
+
+// Replicate sends every message received from in to all upstream client
+// streams, then half-closes them once in is closed. With requireAll, the
+// first failing upstream aborts the replication; otherwise failed upstreams
+// are dropped while the others keep receiving messages, and replication only
+// stops early once none is left. The errors of all failed upstreams are
+// joined into the result either way.
+//
+// The responses of the upstreams are left for the caller to receive.
+func Replicate[Req any](ctx context.Context, in <-chan *Req, streams []grpc.ClientStream, requireAll bool) error {
+	live := slices.Clone(streams)
+	var errs []error
+
+	send := func(fn func(grpc.ClientStream) error) error {
+		for i := 0; i < len(live); {
+			if err := fn(live[i]); err != nil {
+				errs = append(errs, err)
+
+				if requireAll {
+					return errors.Join(errs...)
+				}
+
+				live = slices.Delete(live, i, i+1)
+				continue
+			}
+
+			i++
+		}
+
+		if len(live) == 0 && len(streams) > 0 {
+			return errors.Join(errs...)
+		}
+
+		return nil
+	}
+
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				if err := send(grpc.ClientStream.CloseSend); err != nil {
+					return err
+				}
+
+				return errors.Join(errs...)
+			}
+
+			err := send(func(s grpc.ClientStream) error {
+				return s.SendMsg(msg)
+			})
+
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package grpcasync
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyUploadGreeter fails the first failures uploads with Unavailable once
+// they are fully received, then behaves like greeter.
+type flakyUploadGreeter struct {
+	greeter
+	failures int32
+	attempts atomic.Int32
+}
+
+func (g *flakyUploadGreeter) SayHelloStreamRequest(stream examples.Greeter_SayHelloStreamRequestServer) error {
+	if g.attempts.Add(1) > g.failures {
+		return g.greeter.SayHelloStreamRequest(stream)
+	}
+
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			return status.Error(codes.Unavailable, "try again")
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func uploadItems(names ...string) []*examples.Request {
+	items := make([]*examples.Request, len(names))
+
+	for i, name := range names {
+		items[i] = &examples.Request{Name: name}
+	}
+
+	return items
+}
+
+func TestSendAllRetrying(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int32
+		maxAttempts int
+		wantCode    codes.Code
+		attempts    int32
+	}{
+		{"retried", 1, 3, codes.OK, 2},
+		{"exhausted", 3, 2, codes.Unavailable, 2},
+		{"no attempts", 0, 0, codes.OK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impl := &flakyUploadGreeter{failures: tt.failures}
+			_, conn := startGreeter(t, impl, nil)
+			newStream := func() (grpc.ClientStream, error) {
+				return conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+			}
+
+			res, err := SendAllRetrying[examples.Request, examples.Response](
+				context.Background(), newStream, uploadItems("Alice", "Bob"), tt.maxAttempts)
+
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v (err %v)", code, tt.wantCode, err)
+			} else if err == nil && res.Message != "Hello, Alice, Bob" {
+				t.Errorf("response = %q", res.Message)
+			}
+
+			if got := impl.attempts.Load(); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func replicaStream(t *testing.T) grpc.ClientStream {
+	t.Helper()
+	_, conn := startGreeter(t, &greeter{}, nil)
+	stream, err := conn.NewStream(context.Background(), streamRequestDesc, streamRequestMethod)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stream
+}
+
+func feedRequests(names ...string) <-chan *examples.Request {
+	in := make(chan *examples.Request, len(names))
+
+	for _, req := range uploadItems(names...) {
+		in <- req
+	}
+
+	close(in)
+	return in
+}
+
+func TestReplicate(t *testing.T) {
+	streams := []grpc.ClientStream{replicaStream(t), replicaStream(t)}
+
+	if err := Replicate(context.Background(), feedRequests("Alice", "Bob", "Carol"), streams, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, stream := range streams {
+		res, err := CloseAndRecvCtx[examples.Response](context.Background(), stream)
+
+		if err != nil {
+			t.Fatal(err)
+		} else if res.Message != "Hello, Alice, Bob, Carol" {
+			t.Errorf("upstream %d: response = %q", i, res.Message)
+		}
+	}
+}
+
+func TestReplicateBestEffort(t *testing.T) {
+	for _, requireAll := range []bool{false, true} {
+		// The first upstream fails every send.
+		broken, healthy := replicaStream(t), replicaStream(t)
+		broken.CloseSend()
+
+		err := Replicate(context.Background(), feedRequests("Alice", "Bob"), []grpc.ClientStream{broken, healthy}, requireAll)
+
+		if err == nil {
+			t.Errorf("requireAll=%v: the failed upstream is not reported", requireAll)
+		}
+
+		if requireAll {
+			continue
+		}
+
+		if res, err := CloseAndRecvCtx[examples.Response](context.Background(), healthy); err != nil {
+			t.Fatal(err)
+		} else if res.Message != "Hello, Alice, Bob" {
+			t.Errorf("healthy upstream response = %q", res.Message)
+		}
+	}
+}
+
+func TestUploadBatches(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+
+	// tracking holds each upload for a while, so that they overlap as much as
+	// the concurrency allows.
+	tracking := func(
+		srv any,
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		mu.Lock()
+		active++
+		peak = max(peak, active)
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		return handler(srv, stream)
+	}
+
+	_, conn := startGreeter(t, &greeter{}, []grpc.ServerOption{grpc.StreamInterceptor(tracking)})
+	open := func(ctx context.Context) (grpc.ClientStream, error) {
+		return conn.NewStream(ctx, streamRequestDesc, streamRequestMethod)
+	}
+
+	batches := [][]*examples.Request{uploadItems("a", "b"), uploadItems("c"), uploadItems("d", "e")}
+	res, err := UploadBatches[examples.Request, examples.Response](context.Background(), open, batches, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+
+	for _, r := range res {
+		got = append(got, r.Message)
+	}
+
+	if want := "Hello, a, b|Hello, c|Hello, d, e"; strings.Join(got, "|") != want {
+		t.Errorf("responses = %q, want %q", got, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if peak != 2 {
+		t.Errorf("%d uploads ran at once, want 2", peak)
+	}
+}
+
+// rejectingUploadGreeter rejects the uploads whose first item is "bad", and
+// holds the others until the client cancels them, which it reports on
+// canceled. If held is not nil, it is closed once an upload is held, and
+// rejections wait for it.
+type rejectingUploadGreeter struct {
+	greeter
+	held     chan struct{}
+	canceled chan struct{}
+}
+
+func (g *rejectingUploadGreeter) SayHelloStreamRequest(stream examples.Greeter_SayHelloStreamRequestServer) error {
+	req, err := stream.Recv()
+
+	if err != nil {
+		return err
+	} else if req.Name == "bad" {
+		if g.held != nil {
+			<-g.held
+		}
+
+		return status.Error(codes.InvalidArgument, "bad item")
+	}
+
+	if g.held != nil {
+		close(g.held)
+	}
+
+	<-stream.Context().Done()
+	close(g.canceled)
+
+	return stream.Context().Err()
+}
+
+func TestUploadBatchesFailure(t *testing.T) {
+	impl := &rejectingUploadGreeter{held: make(chan struct{}), canceled: make(chan struct{})}
+	_, conn := startGreeter(t, impl, nil)
+	open := func(ctx context.Context) (grpc.ClientStream, error) {
+		return conn.NewStream(ctx, streamRequestDesc, streamRequestMethod)
+	}
+
+	batches := [][]*examples.Request{uploadItems("hold"), uploadItems("bad")}
+	_, err := UploadBatches[examples.Request, examples.Response](context.Background(), open, batches, 2)
+
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+
+	// The held upload is aborted along with the failed one.
+	select {
+	case <-impl.canceled:
+	case <-time.After(time.Second):
+		t.Error("the other upload was not canceled")
+	}
+}
@@ -0,0 +1,44 @@
+package grpcasync
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RequireFields checks that the named fields of msg are set, i.e. hold a
+// non-zero scalar, a non-empty list or map, or a present message, and returns
+// an InvalidArgument status listing the missing ones otherwise. This enforces
+// required fields by convention in proto3:
+//
+//	if err := grpcasync.RequireFields(req, "name"); err != nil {
+//		return nil, err
+//	}
+//
+// Naming a field msg doesn't have is a programming error and is reported as
+// Internal.
+func RequireFields(msg proto.Message, fields ...string) error {
+	m := msg.ProtoReflect()
+	desc := m.Descriptor()
+	var missing []string
+
+	for _, name := range fields {
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+
+		if fd == nil {
+			return status.Errorf(codes.Internal, "grpcasync: %s has no field %q", desc.FullName(), name)
+		} else if !m.Has(fd) {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return status.Errorf(codes.InvalidArgument, "grpcasync: missing required fields: %s",
+			strings.Join(missing, ", "))
+	}
+
+	return nil
+}
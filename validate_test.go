@@ -0,0 +1,39 @@
+package grpcasync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ayonli/grpc-async/examples"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequireFields(t *testing.T) {
+	client, _ := startGreeter(t, &funcGreeter{sayHello: func(ctx context.Context, req *examples.Request) (*examples.Response, error) {
+		if err := RequireFields(req, "name"); err != nil {
+			return nil, err
+		}
+
+		return &examples.Response{Message: "Hello, " + req.Name}, nil
+	}}, nil)
+
+	_, err := client.SayHello(context.Background(), &examples.Request{})
+
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	} else if msg := status.Convert(err).Message(); !strings.Contains(msg, "name") {
+		t.Errorf("message %q does not name the missing field", msg)
+	}
+
+	if _, err := client.SayHello(context.Background(), &examples.Request{Name: "World"}); err != nil {
+		t.Errorf("err = %v for a request with a name", err)
+	}
+}
+
+func TestRequireFieldsUnknown(t *testing.T) {
+	if err := RequireFields(&examples.Request{Name: "World"}, "nickname"); status.Code(err) != codes.Internal {
+		t.Errorf("err = %v, want Internal", err)
+	}
+}